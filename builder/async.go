@@ -0,0 +1,152 @@
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	pangea "github.com/pangeacyber/pangea-go/pangea-sdk/v3/pangea"
+	"github.com/spf13/cobra"
+)
+
+// Pangea response statuses
+const (
+	StatusSuccess  = "Success"
+	StatusAccepted = "Accepted"
+)
+
+// Endpoint that returns the result of a queued request
+const requestResultPath = "/request/"
+
+// Delays between poll attempts while waiting for a queued request
+const (
+	pollInitialDelay = time.Second
+	pollMaxDelay     = 10 * time.Second
+)
+
+// SendRequest sends a request built by a Pangea client without polling queued results, and returns the full
+// response envelope. It fails if the response status is not `Success` or `Accepted`.
+func SendRequest(ctx context.Context, req *http.Request) (map[string]any, error) {
+	resp, err := cli.HTTPClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var env map[string]any
+	err = json.NewDecoder(resp.Body).Decode(&env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response. HTTP status: %s. Error: %w", resp.Status, err)
+	}
+
+	status, _ := env["status"].(string)
+	if status != StatusSuccess && status != StatusAccepted {
+		summary, _ := env["summary"].(string)
+		return nil, fmt.Errorf("request failed. Status: %s. Summary: %s. Request ID: %v", status, summary, env["request_id"])
+	}
+	return env, nil
+}
+
+// PollRequest requests once the result of a queued request. Returned envelope status is `Accepted` while it is
+// still in progress.
+func PollRequest(ctx context.Context, client *pangea.Client, requestID string) (map[string]any, error) {
+	url, err := client.GetURL(requestResultPath + requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := client.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return SendRequest(ctx, req)
+}
+
+// WaitRequest polls the result of a queued request until it is completed or timeout expires.
+func WaitRequest(ctx context.Context, client *pangea.Client, requestID string, timeout time.Duration) (map[string]any, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	delay := pollInitialDelay
+	for {
+		env, err := PollRequest(ctx, client, requestID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("request %s is still in progress after %s", requestID, timeout)
+			}
+			return nil, err
+		}
+		if IsAccepted(env) {
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("request %s is still in progress after %s", requestID, timeout)
+			case <-time.After(delay):
+			}
+			delay = min(delay*3/2, pollMaxDelay)
+			continue
+		}
+		return env, nil
+	}
+}
+
+// IsAccepted returns true if a response envelope belongs to a request still in progress.
+func IsAccepted(env map[string]any) bool {
+	status, _ := env["status"].(string)
+	return status == StatusAccepted
+}
+
+// PrintEnvelope prints the result of a response envelope, or the full envelope with `raw` output. Requests still
+// in progress are printed as their request ID and status.
+func PrintEnvelope(cmd *cobra.Command, env map[string]any, opts cli.RenderOptions) error {
+	if IsAccepted(env) {
+		return Print(cmd, acceptedResult(env), cli.RenderOptions{})
+	}
+	if getOutputFormat(cmd) == cli.OutputRaw {
+		return Print(cmd, env, opts)
+	}
+	return Print(cmd, env["result"], opts)
+}
+
+func acceptedResult(env map[string]any) map[string]any {
+	return map[string]any{
+		"request_id": env["request_id"],
+		"status":     env["status"],
+	}
+}
+
+// sendAsync sends a request without waiting for queued results. If the request is accepted, its request ID
+// is printed so the result could be collected later with `pangea request` commands.
+func sendAsync(cmd *cobra.Command, req *http.Request, svc string, opts cli.RenderOptions) (map[string]any, error) {
+	env, err := SendRequest(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	if IsAccepted(env) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Request accepted. Get its result running 'pangea request wait %v --service %s'\n", env["request_id"], svc)
+	}
+
+	err = PrintEnvelope(cmd, env, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result, _ := env["result"].(map[string]any)
+	return result, nil
+}
+
+// getPollTimeout returns the value of the `poll-timeout` flag, or def if it was not set.
+func getPollTimeout(cmd *cobra.Command, def time.Duration) time.Duration {
+	flags := CLIFlags(cmd)
+	if !flags.Changed(FlagPollTimeout) {
+		return def
+	}
+	timeout, err := flags.GetDuration(FlagPollTimeout)
+	if err != nil || timeout <= 0 {
+		return def
+	}
+	return timeout
+}
@@ -0,0 +1,296 @@
+package builder
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	pangea "github.com/pangeacyber/pangea-go/pangea-sdk/v3/pangea"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Default number of batch requests sent at the same time
+const DefaultBatchConcurrency = 4
+
+// batchInput is a request body read from a batch file and the line it starts on.
+type batchInput struct {
+	Line int
+	Body map[string]any
+	Err  error
+}
+
+// batchResult is the line written to stdout for each batch request.
+type batchResult struct {
+	Line      int    `json:"line"`
+	RequestID any    `json:"request_id,omitempty"`
+	Status    any    `json:"status,omitempty"`
+	Result    any    `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// runBatch sends a request for each body in the `batch` file, `concurrency` of them at the same time, and
+// writes their results as JSON lines on input order. Input bodies are merged over base, the body built from
+// the `body` flags and the rest of flags, so flags could be used to set common fields.
+// It returns an error if any of the requests failed.
+func runBatch(cmd *cobra.Command, client *pangea.Client, url string, schema *cli.Schema, base map[string]any) error {
+	flags := CLIFlags(cmd)
+	filename, _ := flags.GetString(FlagBatch)
+	concurrency, _ := flags.GetInt(FlagConcurrency)
+	if concurrency < 1 {
+		return fmt.Errorf("`%s` should be greater than 0", FlagConcurrency)
+	}
+	for _, f := range []string{FlagDryRun, FlagAsCurl, FlagAsync} {
+		if v, _ := flags.GetBool(f); v {
+			return fmt.Errorf("`%s` and `%s` flags could not be used together", FlagBatch, f)
+		}
+	}
+
+	inputs, err := readBatchFile(cmd, filename)
+	if err != nil {
+		return err
+	}
+	validate := true
+	if noValidate, _ := flags.GetBool(FlagNoValidate); noValidate {
+		validate = false
+	}
+
+	w := newBatchWriter(os.Stdout, len(inputs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, input := range inputs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, input batchInput) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			w.write(i, sendBatchRequest(client, url, schema, base, input, validate))
+		}(i, input)
+	}
+	wg.Wait()
+
+	if len(w.failed) == 0 {
+		fmt.Fprintf(os.Stderr, "Batch completed. %d requests sent.\n", len(inputs))
+		return nil
+	}
+
+	sort.Ints(w.failed)
+	lines := make([]string, 0, len(w.failed))
+	for _, l := range w.failed {
+		lines = append(lines, fmt.Sprint(l))
+	}
+	return fmt.Errorf("%d of %d batch requests failed. Lines: %s", len(w.failed), len(inputs), strings.Join(lines, ", "))
+}
+
+func sendBatchRequest(client *pangea.Client, url string, schema *cli.Schema, base map[string]any, input batchInput, validate bool) batchResult {
+	res := batchResult{Line: input.Line}
+	if input.Err != nil {
+		res.Error = input.Err.Error()
+		return res
+	}
+
+	body, err := mergeBatchBody(base, input.Body)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	if validate {
+		err = validateBody(schema, body)
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+	}
+
+	req, err := client.NewRequest("POST", url, body)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	var respData map[string]any
+	resp, err := client.Do(context.Background(), req, &respData, true)
+	if resp != nil {
+		res.RequestID = derefString(resp.RequestID)
+		res.Status = derefString(resp.Status)
+	}
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Result = respData
+	return res
+}
+
+// mergeBatchBody returns a copy of base with the fields of body merged over it.
+func mergeBatchBody(base, body map[string]any) (map[string]any, error) {
+	b, err := json.Marshal(base)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := map[string]any{}
+	err = json.Unmarshal(b, &merged)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range body {
+		mergeValue(merged, k, v)
+	}
+	return merged, nil
+}
+
+// batchWriter writes batch results on input order, as soon as all the previous ones are written.
+type batchWriter struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	results []*batchResult
+	next    int
+	failed  []int
+}
+
+func newBatchWriter(w io.Writer, size int) *batchWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &batchWriter{
+		enc:     enc,
+		results: make([]*batchResult, size),
+	}
+}
+
+func (w *batchWriter) write(i int, res batchResult) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if res.Error != "" {
+		w.failed = append(w.failed, res.Line)
+	}
+	w.results[i] = &res
+	for w.next < len(w.results) && w.results[w.next] != nil {
+		if err := w.enc.Encode(w.results[w.next]); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write result of line %d. Error: %v\n", w.results[w.next].Line, err)
+		}
+		w.results[w.next] = nil
+		w.next++
+	}
+}
+
+// readBatchFile reads the request bodies of a batch file. Files with `.csv` extension are read as CSV, where
+// each column of the header is the name of a flag. Any other file is read as JSON lines, one request body per line.
+// Use `-` to read JSON lines from stdin.
+func readBatchFile(cmd *cobra.Command, filename string) ([]batchInput, error) {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open batch file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if strings.EqualFold(filepath.Ext(filename), ".csv") {
+		return readBatchCSV(cmd, r)
+	}
+	return readBatchJSONL(r)
+}
+
+func readBatchJSONL(r io.Reader) ([]batchInput, error) {
+	inputs := []batchInput{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		body, err := parseBody([]byte(text))
+		inputs = append(inputs, batchInput{Line: line, Body: body, Err: err})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %w", err)
+	}
+	if len(inputs) == 0 {
+		return nil, errors.New("batch file is empty")
+	}
+	return inputs, nil
+}
+
+func readBatchCSV(cmd *cobra.Command, r io.Reader) ([]batchInput, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch file header: %w", err)
+	}
+
+	cliFlags := CLIFlags(cmd)
+	flags := make([]*pflag.Flag, 0, len(header))
+	for _, name := range header {
+		name = strings.TrimSpace(name)
+		f := cmd.Flags().Lookup(name)
+		if f == nil || cliFlags.Lookup(name) == f {
+			return nil, fmt.Errorf("invalid batch file column '%s'. Columns should be named after the command flags", name)
+		}
+		flags = append(flags, f)
+	}
+
+	inputs := []batchInput{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read batch file: %w", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+		body, err := csvRecordBody(flags, record)
+		inputs = append(inputs, batchInput{Line: line, Body: body, Err: err})
+	}
+	if len(inputs) == 0 {
+		return nil, errors.New("batch file is empty")
+	}
+	return inputs, nil
+}
+
+// csvRecordBody builds a request body from a CSV record, parsing each cell as the flag of its column would
+// do. Empty cells are skipped.
+func csvRecordBody(flags []*pflag.Flag, record []string) (map[string]any, error) {
+	body := map[string]any{}
+	for i, cell := range record {
+		if i >= len(flags) || cell == "" {
+			continue
+		}
+		f := flags[i]
+
+		// Use a new value of the same flag type, so the command flag is not modified
+		v := reflect.New(reflect.TypeOf(f.Value).Elem()).Interface().(pflag.Value)
+		if err := v.Set(cell); err != nil {
+			return nil, fmt.Errorf("invalid value '%s' for '%s': %w", cell, f.Name, err)
+		}
+
+		var value any = v.String()
+		if pv, ok := v.(PangeaFlag); ok {
+			value = pv.Get()
+		}
+		setFlagValue(body, f, value)
+	}
+	return body, nil
+}
@@ -0,0 +1,270 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Annotation used to pass the request body loaded from `body` or `body-file` flags to the command execution
+const bodyAnnotation = "pangea_request_body"
+
+// Flag annotation with the path of a nested property on the request body
+const bodyPathAnnotation = "pangea_body_path"
+
+// loadRequestBody reads the base request body from the `body` or `body-file` flags. It runs before the
+// required flags validation, so fields provided on the body do not need to be set as flags too.
+// Required flags are not enforced at all if the `no-validate` or `batch` flags are set.
+func loadRequestBody(cmd *cobra.Command, args []string) error {
+	// Let the server validate the request. On batches, each line is validated on its own.
+	noValidate, _ := CLIFlags(cmd).GetBool(FlagNoValidate)
+	batch, _ := CLIFlags(cmd).GetString(FlagBatch)
+	if noValidate || batch != "" {
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			_ = cmd.Flags().SetAnnotation(f.Name, cobra.BashCompOneRequiredFlag, []string{"false"})
+		})
+	}
+
+	if name, _ := CLIFlags(cmd).GetString(FlagFile); name != "" {
+		relaxUploadFlags(cmd)
+	}
+
+	body, err := readBodyFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	delete(cmd.Annotations, bodyAnnotation)
+
+	if body == nil {
+		return nil
+	}
+
+	relaxRequiredFlags(cmd, "", body)
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	cmd.Annotations[bodyAnnotation] = string(b)
+	return nil
+}
+
+// relaxRequiredFlags marks as optional the flags of the fields already present on the body, nested ones included.
+func relaxRequiredFlags(cmd *cobra.Command, parent string, body map[string]any) {
+	for name, value := range body {
+		name = nestedFlagName(parent, name)
+		if cmd.Flags().Lookup(name) != nil {
+			_ = cmd.Flags().SetAnnotation(name, cobra.BashCompOneRequiredFlag, []string{"false"})
+		}
+		if nested, ok := value.(map[string]any); ok {
+			relaxRequiredFlags(cmd, name, nested)
+		}
+	}
+}
+
+// getRequestBody returns the base request body loaded by loadRequestBody, or an empty one.
+func getRequestBody(cmd *cobra.Command) (map[string]any, error) {
+	body := map[string]any{}
+	if cmd.Annotations == nil || cmd.Annotations[bodyAnnotation] == "" {
+		return body, nil
+	}
+
+	err := json.Unmarshal([]byte(cmd.Annotations[bodyAnnotation]), &body)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// buildRequestBody merges the base request body with the values of the flags set by the user.
+// Flag values starting with `@` are replaced by the content of the file they point to.
+func buildRequestBody(cmd *cobra.Command) (map[string]any, error) {
+	data, err := getRequestBody(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	cliFlags := CLIFlags(cmd)
+	var fileErr error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if !f.Changed || fileErr != nil {
+			return
+		}
+
+		// CLI flags should be ignored here. Schema flags with the same name shadow them.
+		if cliFlags.Lookup(f.Name) == f {
+			return
+		}
+
+		var value any = f.Value.String()
+		if v, ok := f.Value.(PangeaFlag); ok {
+			value = v.Get()
+		}
+
+		sv := f.Value.String()
+		// Check if it's a file
+		if strings.HasPrefix(sv, "@") {
+			filename := strings.ReplaceAll(sv, "@", "")
+			content, err := os.ReadFile(filename)
+			if err != nil {
+				fileErr = fmt.Errorf("failed to read file for --%s: %w", f.Name, err)
+				return
+			}
+			value = string(content)
+		}
+
+		setFlagValue(data, f, value)
+	})
+	if fileErr != nil {
+		return nil, fileErr
+	}
+
+	return data, nil
+}
+
+// setFlagValue sets the value of a flag on the request body, at the path of nested flags.
+func setFlagValue(body map[string]any, f *pflag.Flag, value any) {
+	if path, ok := f.Annotations[bodyPathAnnotation]; ok {
+		setPath(body, path, value)
+	} else {
+		mergeValue(body, f.Name, value)
+	}
+}
+
+func readBodyFlags(cmd *cobra.Command) (map[string]any, error) {
+	inline, _ := CLIFlags(cmd).GetString(FlagBody)
+	filename, _ := CLIFlags(cmd).GetString(FlagBodyFile)
+
+	if inline != "" && filename != "" {
+		return nil, fmt.Errorf("only one of `%s` or `%s` flags could be set", FlagBody, FlagBodyFile)
+	}
+
+	var data []byte
+	var err error
+	switch {
+	case inline == "-" || filename == "-":
+		data, err = io.ReadAll(os.Stdin)
+	case inline != "":
+		data = []byte(inline)
+	case filename != "":
+		data, err = os.ReadFile(filename)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	return parseBody(data)
+}
+
+func parseBody(data []byte) (map[string]any, error) {
+	if strings.TrimSpace(string(data)) == "" {
+		return nil, errors.New("request body is empty")
+	}
+
+	var body map[string]any
+	err := json.Unmarshal(data, &body)
+	if err != nil {
+		return nil, fmt.Errorf("request body should be a JSON object: %w", err)
+	}
+	return body, nil
+}
+
+// mergeValue sets value on body[name]. If both the current and the new value are objects, they
+// are merged recursively and the new value takes precedence.
+func mergeValue(body map[string]any, name string, value any) {
+	newMap, ok := toObject(value)
+	if !ok {
+		body[name] = value
+		return
+	}
+
+	current, ok := body[name].(map[string]any)
+	if !ok {
+		body[name] = value
+		return
+	}
+
+	for k, v := range newMap {
+		mergeValue(current, k, v)
+	}
+}
+
+// setBodyPath annotates a nested flag with the path of its property on the request body.
+func setBodyPath(cmd *cobra.Command, parent, name string) {
+	if parent == "" {
+		return
+	}
+	_ = cmd.Flags().SetAnnotation(name, bodyPathAnnotation, strings.Split(name, "."))
+}
+
+// setPath sets value on the nested field of body at path, creating intermediate objects as needed.
+func setPath(body map[string]any, path []string, value any) {
+	if len(path) == 0 {
+		return
+	}
+	if len(path) == 1 {
+		mergeValue(body, path[0], value)
+		return
+	}
+
+	next, ok := body[path[0]].(map[string]any)
+	if !ok {
+		next = map[string]any{}
+		body[path[0]] = next
+	}
+	setPath(next, path[1:], value)
+}
+
+func toObject(value any) (map[string]any, bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		return v, true
+	case map[string]string:
+		m := make(map[string]any, len(v))
+		for k, s := range v {
+			m[k] = s
+		}
+		return m, true
+	default:
+		return nil, false
+	}
+}
+
+// validateBody checks the request body against the request schema, or the variant of it selected by
+// the discriminator field, and returns all the violations in a single error.
+func validateBody(schema *cli.Schema, body map[string]any) error {
+	variant, err := schema.SelectVariant(body)
+	if err != nil {
+		return err
+	}
+
+	errs := variant.Validate(body)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	title := "invalid request body"
+	if variant != schema {
+		prop := schema.DiscriminatorProperty()
+		title = fmt.Sprintf("invalid request body for %s '%v'", prop, body[prop])
+	}
+
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, fmt.Sprintf("  - --%s: %s", e.Field, e.Message))
+	}
+	return fmt.Errorf("%s:\n%s\nUse '--%s' to skip client side validation", title, strings.Join(msgs, "\n"), FlagNoValidate)
+}
@@ -5,8 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
@@ -19,6 +20,41 @@ import (
 	"github.com/spf13/pflag"
 )
 
+// Flags handled by the CLI itself. They are never sent as part of the request body.
+const (
+	FlagCLIProfile  = "cli-profile"
+	FlagOutput      = "output"
+	FlagQuery       = "query"
+	FlagBody        = "body"
+	FlagBodyFile    = "body-file"
+	FlagNoValidate  = "no-validate"
+	FlagDryRun      = "dry-run"
+	FlagAsCurl      = "as-curl"
+	FlagVerbose     = "verbose"
+	FlagTrace       = "trace"
+	FlagTraceFile   = "trace-file"
+	FlagAsync       = "async"
+	FlagPollTimeout = "poll-timeout"
+	FlagBatch       = "batch"
+	FlagConcurrency = "concurrency"
+	FlagRecord      = "record"
+	FlagReplay      = "replay"
+	FlagFile        = "file"
+)
+
+// CLIFlags returns the flags defined by the CLI on a command: the persistent ones of the command and its
+// parents, and the `file` flag of multipart uploads. Request schemas could have fields with the same
+// name, and their flags shadow the CLI ones on cmd.Flags().
+func CLIFlags(cmd *cobra.Command) *pflag.FlagSet {
+	flags := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+	flags.AddFlagSet(cmd.InheritedFlags())
+	flags.AddFlagSet(cmd.PersistentFlags())
+	if f := cmd.Flags().Lookup(FlagFile); f != nil && len(f.Annotations[uploadAnnotation]) > 0 {
+		flags.AddFlag(f)
+	}
+	return flags
+}
+
 type Builder struct {
 	cmdMap *CommandsMap
 }
@@ -42,35 +78,32 @@ func (b *Builder) Cmd() *cobra.Command {
 
 // AddPangeaCommand creates a new command from an openapi path spec.
 func (b *Builder) AddPangeaCommand(config *pangea.Config, svc, pathCmd, pathAPI string, post cli.PathPost, version string) {
-	if post.XPangeaUISchema != nil && post.XPangeaUISchema.IsConfiguration != nil && *post.XPangeaUISchema.IsConfiguration {
+	if isConfiguration(post) {
 		return
 	}
+	b.addIndexedCommand(config, svc, newIndexedCommand(pathCmd, pathAPI, post, version))
+}
 
-	groupID := ""
-	if post.Tags != nil && len(post.Tags) == 1 {
-		groupID = post.Tags[0]
-	}
-
-	short := post.Summary
-	if len(short) < 30 { // If it's too short, use description
-		short = strings.Split(post.Description, "\n")[0]
-	}
-
+func (b *Builder) addIndexedCommand(config *pangea.Config, svc string, c IndexedCommand) {
 	cmd := &cobra.Command{
-		Use:     pathCmd,
-		Short:   cleanFormat(short),
-		Long:    cleanFormat(post.Description),
-		RunE:    b.executePangeaRequest(config, svc, pathAPI),
-		GroupID: groupID,
+		Use:     c.Name,
+		Short:   c.Short,
+		Long:    c.Long,
+		PreRunE: loadRequestBody,
+		RunE:    b.executePangeaRequest(config, svc, c.Path, c.Post),
+		GroupID: c.GroupID,
 		Annotations: map[string]string{
-			"version": version,
+			"version": c.Version,
 		},
 	}
 
-	addSchema(cmd, post.RequestBody.Content[cli.ApplicationJSON].Schema, true)
-	err := b.AddCommand([]string{svc, version, pathCmd}, cmd)
+	addFlags(cmd, c.Flags)
+	if schema := requestSchema(c.Post); schema != nil {
+		setVariantHelp(cmd, schema)
+	}
+	err := b.AddCommand([]string{svc, c.Version, c.Name}, cmd)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to add service[%s] command path[%s]. Error: %v", svc, pathCmd, err)
+		fmt.Fprintf(os.Stderr, "Failed to add service[%s] command path[%s]. Error: %v", svc, c.Name, err)
 	}
 }
 
@@ -139,13 +172,15 @@ func mergeDescriptions(oldDesc, newDesc string) string {
 	return updateDesc + newDesc
 }
 
-// addParameters add a list of properties as flags to a command
+// addParameters add a list of properties as flags to a command. Properties of objects with a known
+// schema are added as nested flags named `<parent>.<property>`.
 // WIP
-func addParameters(cmd *cobra.Command, props cli.Properties, required []string) {
-	for name, prop := range props {
-		propType := string(prop.Type)
+func addParameters(cmd *cobra.Command, parent string, props cli.Properties, required []string) {
+	for propName, prop := range props {
+		types := prop.NonNullTypes()
+		name := nestedFlagName(parent, propName)
 
-		if strings.Contains(propType, `"string"`) || isConstStringEnum(prop) {
+		if (len(types) <= 1 && prop.HasType("string")) || isConstStringEnum(prop) {
 			var def string
 			def, _ = prop.Default.(string)
 			vals := propEnumValues(prop)
@@ -159,6 +194,7 @@ func addParameters(cmd *cobra.Command, props cli.Properties, required []string)
 					fe = NewFlagEnum(name, vals)
 					descr := mergeDescriptions(prop.Description, fe.Description())
 					cmd.Flags().Var(fe, name, descr)
+					setBodyPath(cmd, parent, name)
 				}
 
 				// add the flag values to the autocomplete scripts
@@ -174,6 +210,17 @@ func addParameters(cmd *cobra.Command, props cli.Properties, required []string)
 			}
 
 			cmd.Flags().String(name, def, prop.Description)
+			setBodyPath(cmd, parent, name)
+			continue
+		}
+
+		if len(types) == 1 && prop.HasType("object") && len(prop.Properties) > 0 {
+			// Nested properties are only required if its parent object is required
+			var nestedRequired []string
+			if slices.Contains(required, propName) {
+				nestedRequired = prop.Required
+			}
+			addParameters(cmd, name, prop.Properties, nestedRequired)
 			continue
 		}
 
@@ -182,39 +229,36 @@ func addParameters(cmd *cobra.Command, props cli.Properties, required []string)
 			continue
 		}
 
-		if strings.Contains(propType, `"integer"`) {
+		switch {
+		case len(types) > 1:
+			var f FlagJSON
+			help := fmt.Sprintf("Could be %s. Values are parsed as JSON, or taken as strings if they're not valid JSON.", strings.Join(types, " or "))
+			cmd.Flags().Var(&f, name, cleanFormat(mergeDescriptions(prop.Description, help)))
+		case prop.HasType("integer"):
 			var f FlagInteger
 			cmd.Flags().Var(&f, name, prop.Description)
-			continue
-		}
-
-		if strings.Contains(propType, `"boolean"`) {
+		case prop.HasType("number"):
+			var f FlagNumber
+			cmd.Flags().Var(&f, name, prop.Description)
+		case prop.HasType("boolean"):
 			var f FlagBool
 			cmd.Flags().Var(&f, name, prop.Description)
-			continue
-		}
-
-		if strings.Contains(propType, `"object"`) {
+		case prop.HasType("object"):
 			var f FlagMap
 			help := fmt.Sprintf("CLI use: '--%s key1:value1,key2:value2'.", name)
 			cmd.Flags().Var(&f, name, cleanFormat(mergeDescriptions(prop.Description, help)))
-			continue
+		case prop.HasType("array"):
+			addArrayParameter(cmd, name, prop)
+		default:
+			// By default add flag as Any. Could be string or an object. It apply to some `redact fields`
+			var f FlagAny
+			cmd.Flags().Var(&f, name, cleanFormat(prop.Description))
 		}
-
-		if strings.Contains(propType, `"array"`) {
-			var f FlagArray
-			help := fmt.Sprintf("CLI use: '--%s value1,value2'.", name)
-			cmd.Flags().Var(&f, name, cleanFormat(mergeDescriptions(prop.Description, help)))
-			continue
-		}
-
-		// By default add flag as Any. Could be string or an object. It apply to some `redact fields`
-		var f FlagAny
-		cmd.Flags().Var(&f, name, cleanFormat(prop.Description))
+		setBodyPath(cmd, parent, name)
 	}
 
 	for _, flag := range required {
-		cmd.MarkFlagRequired(flag) //nolint:errcheck
+		cmd.MarkFlagRequired(nestedFlagName(parent, flag)) //nolint:errcheck
 		// err := cmd.MarkFlagRequired(flag)
 		// if err != nil {
 		// 	fmt.Fprintf(os.Stderr, "Failed to mark flag as required: %v\n", err)
@@ -222,6 +266,38 @@ func addParameters(cmd *cobra.Command, props cli.Properties, required []string)
 	}
 }
 
+// addArrayParameter adds the flag of an array property, typed after its items.
+func addArrayParameter(cmd *cobra.Command, name string, prop cli.Property) {
+	var items cli.Property
+	if prop.Items != nil {
+		items = *prop.Items
+	}
+
+	var f pflag.Value
+	help := fmt.Sprintf("CLI use: '--%s value1,value2'.", name)
+	switch itemTypes := items.NonNullTypes(); {
+	case len(itemTypes) != 1:
+		f = &FlagArray{}
+	case items.HasType("integer"):
+		f = &FlagIntegerArray{}
+	case items.HasType("number"):
+		f = &FlagNumberArray{}
+	case items.HasType("object"):
+		f = &FlagObjectArray{}
+		help = fmt.Sprintf(`CLI use: '--%s {"key":"value"}'. Repeat the flag, or set a JSON array, to add several objects.`, name)
+	default:
+		f = &FlagArray{}
+	}
+	cmd.Flags().Var(f, name, cleanFormat(mergeDescriptions(prop.Description, help)))
+}
+
+func nestedFlagName(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
 func cleanMD(in string) string {
 	emd := pe.NewMarkdownExtractor()
 	r, err := emd.PlainText(in)
@@ -253,7 +329,7 @@ func addSchema(cmd *cobra.Command, schema *cli.Schema, wr bool) {
 		required = schema.Required
 	}
 
-	addParameters(cmd, schema.Properties, required)
+	addParameters(cmd, "", schema.Properties, required)
 	for _, sch := range schema.OneOf {
 		addSchema(cmd, &sch, false)
 	}
@@ -448,60 +524,67 @@ func newVersionData(data string) (*versionData, error) {
 }
 
 // executePangeaRequest returns a function that executes a command against Pangea API, using the
-// values of the flags as the body of the request.
-func (b *Builder) executePangeaRequest(config *pangea.Config, svc, pathAPI string) func(cmd *cobra.Command, args []string) error {
+// values of the flags as the body of the request. Flags override the fields of the base body set
+// with the `body` or `body-file` flags.
+func (b *Builder) executePangeaRequest(config *pangea.Config, svc, pathAPI string, post cli.PathPost) func(cmd *cobra.Command, args []string) error {
+	renderOpts := responseRenderOptions(post)
+
 	getClient := func(cmd *cobra.Command) (*pangea.Client, error) {
-		profile, err := cmd.Flags().GetString("cli-profile")
+		profile, err := CLIFlags(cmd).GetString(FlagCLIProfile)
 		if err != nil {
 			return nil, err
 		}
 		if profile != "" {
-			config.Token, config.Domain, err = cli.GetProfileTokenAndDomain(profile, svc)
+			var domain string
+			config.Token, domain, err = cli.GetProfileTokenAndDomain(profile, svc)
 			if err != nil {
 				return nil, err
 			}
+			cli.SetDomain(config, domain)
 		}
 
+		config.PollResultTimeout = getPollTimeout(cmd, config.PollResultTimeout)
+
 		return pangea.NewClient(svc, config), nil
 	}
 
 	return func(cmd *cobra.Command, args []string) error {
-		data := map[string]any{}
-		cmd.Flags().VisitAll(func(f *pflag.Flag) {
-			if !f.Changed {
-				return
-			}
+		output := getOutputFormat(cmd)
+		if err := cli.ValidateOutputFormat(output); err != nil {
+			return err
+		}
+		if err := cli.ValidateQuery(getQuery(cmd)); err != nil {
+			return err
+		}
 
-			// `cli-profile` flag should be ignored here
-			if f.Name == "cli-profile" {
-				return
-			}
+		data, err := buildRequestBody(cmd)
+		if err != nil {
+			return err
+		}
 
-			sv := f.Value.String()
-			// Check if it's a file
-			if strings.HasPrefix(sv, "@") {
-				filename := strings.ReplaceAll(sv, "@", "")
-				file, err := os.Open(filename)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Failed to open file: %s\n", filename)
-					return
-				}
-				defer file.Close()
-				content, err := io.ReadAll(file)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Failed to read file: %s\n", filename)
-					return
-				}
-				data[f.Name] = string(content)
-				return
+		schema := requestSchema(post)
+		batch, _ := CLIFlags(cmd).GetString(FlagBatch)
+
+		upload, err := openUpload(cmd, post)
+		if err != nil {
+			return err
+		}
+		if upload != nil {
+			defer upload.Close()
+			if batch != "" {
+				return fmt.Errorf("only one of `%s` or `%s` flags could be set", FlagBatch, FlagFile)
 			}
+			if err := upload.fillParams(schema, data); err != nil {
+				return err
+			}
+		}
 
-			if v, ok := f.Value.(PangeaFlag); ok {
-				data[f.Name] = v.Get()
-			} else {
-				data[f.Name] = f.Value.String()
+		if noValidate, _ := CLIFlags(cmd).GetBool(FlagNoValidate); !noValidate && batch == "" && schema != nil {
+			err = validateBody(schema, data)
+			if err != nil {
+				return err
 			}
-		})
+		}
 
 		client, err := getClient(cmd)
 		if err != nil {
@@ -513,19 +596,59 @@ func (b *Builder) executePangeaRequest(config *pangea.Config, svc, pathAPI strin
 			return err
 		}
 
+		if batch != "" {
+			return runBatch(cmd, client, url, schema, data)
+		}
+
 		req, err := client.NewRequest("POST", url, data)
 		if err != nil {
 			return err
 		}
 
-		ctx := context.Background()
-		var respData map[string]any
-		_, err = client.Do(ctx, req, &respData, true)
-		if err != nil {
-			return err
+		requestPart, _, _ := multipartParts(post)
+		asCurl, _ := CLIFlags(cmd).GetBool(FlagAsCurl)
+		dryRun, _ := CLIFlags(cmd).GetBool(FlagDryRun)
+		switch {
+		case asCurl && upload != nil:
+			return printUploadCurl(os.Stdout, req, requestPart, upload)
+		case asCurl:
+			return printCurl(os.Stdout, req)
+		case dryRun && upload != nil:
+			return printUploadDryRun(os.Stdout, req, requestPart, upload)
+		case dryRun:
+			return printDryRun(os.Stdout, req)
+		}
+
+		if upload != nil {
+			req, err = newUploadRequest(client, url, requestPart, data, upload)
+			if err != nil {
+				return err
+			}
 		}
 
-		cli.PrettyPrint(respData)
+		var respData map[string]any
+		if async, _ := CLIFlags(cmd).GetBool(FlagAsync); async {
+			respData, err = sendAsync(cmd, req, svc, renderOpts)
+			if err != nil {
+				return err
+			}
+		} else {
+			ctx := context.Background()
+			resp, err := client.Do(ctx, req, &respData, true)
+			if err != nil {
+				return err
+			}
+
+			var out any = respData
+			if output == cli.OutputRaw {
+				out = envelope(resp, respData)
+			}
+
+			err = Print(cmd, out, renderOpts)
+			if err != nil {
+				return err
+			}
+		}
 
 		b, err := json.Marshal(respData)
 		if err == nil {
@@ -538,3 +661,113 @@ func (b *Builder) executePangeaRequest(config *pangea.Config, svc, pathAPI strin
 		return nil
 	}
 }
+
+// Print applies the `query` flag expression to data and writes it to stdout using the format set
+// on the `output` flag. Plugins should use it to print their results so they behave like service commands.
+func Print(cmd *cobra.Command, data any, opts cli.RenderOptions) error {
+	output := getOutputFormat(cmd)
+	query := getQuery(cmd)
+
+	data, err := cli.Query(query, data)
+	if err != nil {
+		return err
+	}
+
+	// Schema hints do not apply once the response was reshaped by a query
+	if query != "" {
+		opts = cli.RenderOptions{}
+	}
+	opts.Color = cli.ColorEnabled()
+	return cli.Render(os.Stdout, output, data, opts)
+}
+
+// StructuredOutputRequested returns true if the user set the `output` or `query` flags. Plugins that
+// print plain text by default use it to switch to Print.
+func StructuredOutputRequested(cmd *cobra.Command) bool {
+	flags := CLIFlags(cmd)
+	return flags.Changed(FlagOutput) || flags.Changed(FlagQuery)
+}
+
+// getQuery returns the value of the `query` flag, or an empty string if the command does not have it.
+// Request fields named `query` are not taken as a JMESPath expression.
+func getQuery(cmd *cobra.Command) string {
+	query, err := CLIFlags(cmd).GetString(FlagQuery)
+	if err != nil {
+		return ""
+	}
+	return query
+}
+
+// getOutputFormat returns the value of the `output` flag, or `json` if the command does not have it.
+func getOutputFormat(cmd *cobra.Command) string {
+	output, err := CLIFlags(cmd).GetString(FlagOutput)
+	if err != nil || output == "" {
+		return cli.OutputJSON
+	}
+	return output
+}
+
+// responseRenderOptions returns the render hints declared on the success response schema of an
+// endpoint. Pangea responses wrap the result on the `result` field of the envelope.
+func responseRenderOptions(post cli.PathPost) cli.RenderOptions {
+	opts := cli.RenderOptions{}
+
+	resp, ok := post.Responses["200"]
+	if !ok {
+		return opts
+	}
+	content, ok := resp.Content[cli.ApplicationJSON]
+	if !ok || content.Schema == nil {
+		return opts
+	}
+
+	props := content.Schema.Properties
+	if result, ok := props["result"]; ok {
+		props = result.Properties
+	}
+
+	lists := []string{}
+	for name, prop := range props {
+		opts.Fields = append(opts.Fields, name)
+		if prop.HasType("array") {
+			lists = append(lists, name)
+		}
+	}
+	sort.Strings(opts.Fields)
+
+	// Only use it as list of results if there is no ambiguity
+	if len(lists) == 1 {
+		opts.ListField = lists[0]
+		if items := props[opts.ListField].Items; items != nil {
+			for name := range items.Properties {
+				opts.ListColumns = append(opts.ListColumns, name)
+			}
+			sort.Strings(opts.ListColumns)
+		}
+	}
+	return opts
+}
+
+// envelope rebuilds the full Pangea response, including the response header fields.
+func envelope(resp *pangea.Response, result any) map[string]any {
+	e := map[string]any{
+		"result": result,
+	}
+	if resp == nil {
+		return e
+	}
+
+	e["request_id"] = derefString(resp.RequestID)
+	e["request_time"] = derefString(resp.RequestTime)
+	e["response_time"] = derefString(resp.ResponseTime)
+	e["status"] = derefString(resp.Status)
+	e["summary"] = derefString(resp.Summary)
+	return e
+}
+
+func derefString(s *string) any {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
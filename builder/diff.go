@@ -0,0 +1,184 @@
+package builder
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+)
+
+// Kinds of spec changes
+const (
+	ChangeCommandAdded      = "command_added"
+	ChangeCommandRemoved    = "command_removed"
+	ChangeFlagAdded         = "flag_added"
+	ChangeFlagRemoved       = "flag_removed"
+	ChangeFlagRenamed       = "flag_renamed"
+	ChangeFlagTypeChanged   = "flag_type_changed"
+	ChangeFlagRequired      = "flag_required"
+	ChangeFlagNotRequired   = "flag_not_required"
+	ChangeEnumValuesAdded   = "enum_values_added"
+	ChangeEnumValuesRemoved = "enum_values_removed"
+)
+
+// SpecChange is a change on the commands of a service between two versions of its spec.
+type SpecChange struct {
+	Change  string `json:"change"`
+	Command string `json:"command"`
+	Flag    string `json:"flag,omitempty"`
+	// Previous flag name or type, for renamed flags and type changes
+	From string `json:"from,omitempty"`
+	// New flag type for type changes
+	To     string   `json:"to,omitempty"`
+	Values []string `json:"values,omitempty"`
+}
+
+type flagInfo struct {
+	typ      string
+	usage    string
+	required bool
+	values   []string
+}
+
+// DiffSpecs returns the changes on the commands built from the old spec to the ones built from the new
+// one, sorted by command.
+func DiffSpecs(oldSpec, newSpec *cli.OpenAPI) []SpecChange {
+	oldCmds := indexedCommands(oldSpec)
+	newCmds := indexedCommands(newSpec)
+
+	changes := []SpecChange{}
+	for name := range oldCmds {
+		if _, ok := newCmds[name]; !ok {
+			changes = append(changes, SpecChange{Change: ChangeCommandRemoved, Command: name})
+		}
+	}
+	for name, c := range newCmds {
+		old, ok := oldCmds[name]
+		if !ok {
+			changes = append(changes, SpecChange{Change: ChangeCommandAdded, Command: name})
+			continue
+		}
+		changes = append(changes, diffFlags(name, commandFlags(old), commandFlags(c))...)
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Command != changes[j].Command {
+			return changes[i].Command < changes[j].Command
+		}
+		if changes[i].Flag != changes[j].Flag {
+			return changes[i].Flag < changes[j].Flag
+		}
+		return changes[i].Change < changes[j].Change
+	})
+	return changes
+}
+
+// indexedCommands returns the commands of a spec by the words used to invoke them, like `v1 /list`.
+func indexedCommands(oapi *cli.OpenAPI) map[string]IndexedCommand {
+	cmds := map[string]IndexedCommand{}
+	for _, c := range NewCommandIndex(oapi, "").Commands {
+		cmds[strings.TrimSpace(c.Version+" "+c.Name)] = c
+	}
+	return cmds
+}
+
+// commandFlags returns the flags of the command built from an indexed one.
+func commandFlags(c IndexedCommand) map[string]flagInfo {
+	flags := map[string]flagInfo{}
+	for _, d := range c.Flags {
+		flags[d.Name] = flagInfo{
+			typ:      d.Type,
+			usage:    d.Usage,
+			required: d.Required,
+			values:   slices.Sorted(slices.Values(d.Values)),
+		}
+	}
+	return flags
+}
+
+// diffFlags compares the flags of a command. A removed flag is reported as renamed if there is a single
+// added one with its type and description.
+func diffFlags(command string, oldFlags, newFlags map[string]flagInfo) []SpecChange {
+	changes := []SpecChange{}
+	removed, added := []string{}, []string{}
+	for name := range oldFlags {
+		if _, ok := newFlags[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	for name, nf := range newFlags {
+		of, ok := oldFlags[name]
+		if !ok {
+			added = append(added, name)
+			continue
+		}
+		changes = append(changes, diffFlag(command, name, of, nf)...)
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+
+	renamed := map[string]string{}
+	taken := map[string]bool{}
+	for _, from := range removed {
+		of := oldFlags[from]
+		candidates := []string{}
+		for _, to := range added {
+			if !taken[to] && of.usage != "" && of.typ == newFlags[to].typ && of.usage == newFlags[to].usage {
+				candidates = append(candidates, to)
+			}
+		}
+		if len(candidates) == 1 {
+			renamed[from] = candidates[0]
+			taken[candidates[0]] = true
+		}
+	}
+
+	for _, from := range removed {
+		to, ok := renamed[from]
+		if !ok {
+			changes = append(changes, SpecChange{Change: ChangeFlagRemoved, Command: command, Flag: from})
+			continue
+		}
+		changes = append(changes, SpecChange{Change: ChangeFlagRenamed, Command: command, Flag: to, From: from})
+		changes = append(changes, diffFlag(command, to, oldFlags[from], newFlags[to])...)
+	}
+	for _, to := range added {
+		if !taken[to] {
+			changes = append(changes, SpecChange{Change: ChangeFlagAdded, Command: command, Flag: to})
+		}
+	}
+	return changes
+}
+
+// diffFlag compares the type, required state and values of a flag.
+func diffFlag(command, name string, of, nf flagInfo) []SpecChange {
+	changes := []SpecChange{}
+	if of.typ != nf.typ {
+		changes = append(changes, SpecChange{Change: ChangeFlagTypeChanged, Command: command, Flag: name, From: of.typ, To: nf.typ})
+	}
+	if !of.required && nf.required {
+		changes = append(changes, SpecChange{Change: ChangeFlagRequired, Command: command, Flag: name})
+	}
+	if of.required && !nf.required {
+		changes = append(changes, SpecChange{Change: ChangeFlagNotRequired, Command: command, Flag: name})
+	}
+	if values := missingValues(of.values, nf.values); len(values) > 0 {
+		changes = append(changes, SpecChange{Change: ChangeEnumValuesAdded, Command: command, Flag: name, Values: values})
+	}
+	if values := missingValues(nf.values, of.values); len(values) > 0 {
+		changes = append(changes, SpecChange{Change: ChangeEnumValuesRemoved, Command: command, Flag: name, Values: values})
+	}
+	return changes
+}
+
+// missingValues returns the values on b that aren't on a.
+func missingValues(a, b []string) []string {
+	missing := []string{}
+	for _, v := range b {
+		if !slices.Contains(a, v) {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
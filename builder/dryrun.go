@@ -0,0 +1,102 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+)
+
+// Environment variable referenced on curl commands instead of the actual token
+const curlTokenVariable = "$PANGEA_TOKEN"
+
+// printDryRun writes the request that would be sent to Pangea, with the token masked.
+func printDryRun(w io.Writer, req *http.Request) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%s %s\n", req.Method, req.URL)
+	for _, name := range sortedHeaders(req.Header) {
+		for _, v := range req.Header.Values(name) {
+			if name == "Authorization" {
+				v = cli.MaskAuthorization(v)
+			}
+			fmt.Fprintf(w, "%s: %s\n", name, v)
+		}
+	}
+
+	if len(body) > 0 {
+		var indented bytes.Buffer
+		if json.Indent(&indented, body, "", "  ") == nil {
+			body = indented.Bytes()
+		}
+		fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// printCurl writes a curl command equivalent to the request. The token is read from the PANGEA_TOKEN
+// environment variable, so the command could be shared as is.
+func printCurl(w io.Writer, req *http.Request) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+
+	parts := []string{fmt.Sprintf("curl -X %s %s", req.Method, shellQuote(req.URL.String()))}
+	parts = append(parts, curlHeaders(req)...)
+	if len(body) > 0 {
+		parts = append(parts, "--data-raw "+shellQuote(strings.TrimSpace(string(body))))
+	}
+
+	fmt.Fprintln(w, strings.Join(parts, " \\\n  "))
+	return nil
+}
+
+// curlHeaders returns the curl options that set the headers of a request, but the skipped ones.
+func curlHeaders(req *http.Request, skip ...string) []string {
+	options := []string{}
+	for _, name := range sortedHeaders(req.Header) {
+		if slices.Contains(skip, name) {
+			continue
+		}
+		for _, v := range req.Header.Values(name) {
+			if name == "Authorization" {
+				options = append(options, fmt.Sprintf(`-H "Authorization: Bearer %s"`, curlTokenVariable))
+				continue
+			}
+			options = append(options, "-H "+shellQuote(name+": "+v))
+		}
+	}
+	return options
+}
+
+// requestBody reads the body of a request that is not going to be sent.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	return io.ReadAll(req.Body)
+}
+
+func sortedHeaders(h http.Header) []string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
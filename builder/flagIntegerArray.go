@@ -0,0 +1,52 @@
+// FlagIntegerArray class implements a custom Cobra integer array flag.
+
+package builder
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+)
+
+type FlagIntegerArray struct {
+	values []int
+}
+
+func (f *FlagIntegerArray) Type() string {
+	return "integerArray"
+}
+
+func (f *FlagIntegerArray) String() string {
+	if len(f.values) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(f.values)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func (f *FlagIntegerArray) Set(in string) error {
+	vals, err := cli.ReadAsCSV(in)
+	if err != nil {
+		return err
+	}
+	for _, val := range vals {
+		v, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil {
+			return err
+		}
+		f.values = append(f.values, v)
+	}
+	return nil
+}
+
+func (f *FlagIntegerArray) Get() any {
+	return f.values
+}
+
+// Ensure FlagIntegerArray implements PangeaFlag
+var _ PangeaFlag = (*FlagIntegerArray)(nil)
@@ -0,0 +1,44 @@
+// FlagJSON class implements a custom Cobra flag for properties that allow several types. Values are
+// parsed as JSON, or taken as strings if they're not valid JSON.
+
+package builder
+
+import (
+	"encoding/json"
+)
+
+type FlagJSON struct {
+	value any
+}
+
+func (f *FlagJSON) Type() string {
+	return "json"
+}
+
+func (f *FlagJSON) String() string {
+	if f.value == nil {
+		return ""
+	}
+	if s, ok := f.value.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(f.value)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func (f *FlagJSON) Set(in string) error {
+	if err := json.Unmarshal([]byte(in), &f.value); err != nil {
+		f.value = in
+	}
+	return nil
+}
+
+func (f *FlagJSON) Get() any {
+	return f.value
+}
+
+// Ensure FlagJSON implements PangeaFlag
+var _ PangeaFlag = (*FlagJSON)(nil)
@@ -0,0 +1,38 @@
+// FlagNumber class implements a custom Cobra floating point number flag
+
+package builder
+
+import (
+	"strconv"
+)
+
+type FlagNumber struct {
+	value *float64
+}
+
+func (f *FlagNumber) Type() string {
+	return "number"
+}
+
+func (f *FlagNumber) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f.value, 'f', -1, 64)
+}
+
+func (f *FlagNumber) Set(in string) error {
+	v, err := strconv.ParseFloat(in, 64)
+	if err != nil {
+		return err
+	}
+	f.value = &v
+	return nil
+}
+
+func (f *FlagNumber) Get() any {
+	return f.value
+}
+
+// Ensure FlagNumber implements PangeaFlag
+var _ PangeaFlag = (*FlagNumber)(nil)
@@ -0,0 +1,52 @@
+// FlagNumberArray class implements a custom Cobra floating point number array flag.
+
+package builder
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+)
+
+type FlagNumberArray struct {
+	values []float64
+}
+
+func (f *FlagNumberArray) Type() string {
+	return "numberArray"
+}
+
+func (f *FlagNumberArray) String() string {
+	if len(f.values) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(f.values)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func (f *FlagNumberArray) Set(in string) error {
+	vals, err := cli.ReadAsCSV(in)
+	if err != nil {
+		return err
+	}
+	for _, val := range vals {
+		v, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			return err
+		}
+		f.values = append(f.values, v)
+	}
+	return nil
+}
+
+func (f *FlagNumberArray) Get() any {
+	return f.values
+}
+
+// Ensure FlagNumberArray implements PangeaFlag
+var _ PangeaFlag = (*FlagNumberArray)(nil)
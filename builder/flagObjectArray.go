@@ -0,0 +1,54 @@
+// FlagObjectArray class implements a custom Cobra flag for arrays of JSON objects. Each value is an
+// object, or an array of them.
+
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type FlagObjectArray struct {
+	values []map[string]any
+}
+
+func (f *FlagObjectArray) Type() string {
+	return "objectArray"
+}
+
+func (f *FlagObjectArray) String() string {
+	if len(f.values) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(f.values)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func (f *FlagObjectArray) Set(in string) error {
+	if strings.HasPrefix(strings.TrimSpace(in), "[") {
+		var vals []map[string]any
+		if err := json.Unmarshal([]byte(in), &vals); err != nil {
+			return fmt.Errorf("invalid JSON array of objects: %w", err)
+		}
+		f.values = append(f.values, vals...)
+		return nil
+	}
+
+	var v map[string]any
+	if err := json.Unmarshal([]byte(in), &v); err != nil {
+		return fmt.Errorf("invalid JSON object: %w", err)
+	}
+	f.values = append(f.values, v)
+	return nil
+}
+
+func (f *FlagObjectArray) Get() any {
+	return f.values
+}
+
+// Ensure FlagObjectArray implements PangeaFlag
+var _ PangeaFlag = (*FlagObjectArray)(nil)
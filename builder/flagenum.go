@@ -30,14 +30,11 @@ func (f *FlagEnum) String() string {
 	return f.value
 }
 
+// Set does not check val against the possible values. Request validation does it, so all the errors are
+// reported at once and it could be skipped if the spec is outdated.
 func (f *FlagEnum) Set(val string) error {
-	for _, v := range f.enum {
-		if val == v {
-			f.value = val
-			return nil
-		}
-	}
-	return fmt.Errorf("invalid value %s. Possible values: [%s]", val, strings.Join(f.enum, " "))
+	f.value = val
+	return nil
 }
 
 func (f *FlagEnum) Get() any {
@@ -0,0 +1,250 @@
+package builder
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	pangea "github.com/pangeacyber/pangea-go/pangea-sdk/v3/pangea"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Version of the layout of command indexes. Indexes saved with another one are built again.
+const commandIndexFormat = 3
+
+// CommandIndex is the precompiled form of the commands of a service spec: references are resolved,
+// configuration endpoints are skipped and help texts are cleaned. Building commands from it avoids
+// parsing the whole spec on every run.
+type CommandIndex struct {
+	Format     int              `json:"format"`
+	CLIVersion string           `json:"cli_version"`
+	SpecHash   string           `json:"spec_hash"`
+	Commands   []IndexedCommand `json:"commands"`
+}
+
+// IndexedCommand holds everything needed to build the command of an endpoint. Its flags are built
+// from Flags, and Post is used to send and validate requests and render their results.
+type IndexedCommand struct {
+	Name    string           `json:"name"`
+	Version string           `json:"version,omitempty"`
+	Path    string           `json:"path"`
+	Short   string           `json:"short,omitempty"`
+	Long    string           `json:"long,omitempty"`
+	GroupID string           `json:"group,omitempty"`
+	Flags   []FlagDescriptor `json:"flags,omitempty"`
+	Post    cli.PathPost     `json:"post"`
+}
+
+// FlagDescriptor is a flag of an indexed command, taken from the request schema when the index is
+// built. Type is the type of the flag value, like `integer` or `string (enum)`.
+type FlagDescriptor struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Usage    string   `json:"usage,omitempty"`
+	Default  string   `json:"default,omitempty"`
+	Values   []string `json:"values,omitempty"`
+	Required bool     `json:"required,omitempty"`
+	BodyPath []string `json:"body_path,omitempty"`
+	Upload   bool     `json:"upload,omitempty"`
+}
+
+// NewCommandIndex precompiles the commands of a parsed spec.
+func NewCommandIndex(oapi *cli.OpenAPI, specHash string) *CommandIndex {
+	idx := &CommandIndex{
+		Format:     commandIndexFormat,
+		CLIVersion: cli.Version,
+		SpecHash:   specHash,
+		Commands:   []IndexedCommand{},
+	}
+
+	for path, pathDef := range oapi.Paths {
+		if isConfiguration(pathDef.Post) {
+			continue
+		}
+		name, version := commandName(path)
+		idx.Commands = append(idx.Commands, newIndexedCommand(name, path, pathDef.Post, version))
+	}
+	sort.Slice(idx.Commands, func(i, j int) bool {
+		return idx.Commands[i].Path < idx.Commands[j].Path
+	})
+	return idx
+}
+
+// commandName splits an API path in its version and the command name, like `v1` and `/list` for
+// `/v1/list`.
+func commandName(path string) (string, string) {
+	version := ""
+	ndx := strings.Index(path[1:], "/")
+	if ndx >= 0 {
+		version = path[1 : ndx+1]
+	}
+	return path[ndx+1:], version
+}
+
+func isConfiguration(post cli.PathPost) bool {
+	return post.XPangeaUISchema != nil && post.XPangeaUISchema.IsConfiguration != nil && *post.XPangeaUISchema.IsConfiguration
+}
+
+func newIndexedCommand(name, path string, post cli.PathPost, version string) IndexedCommand {
+	groupID := ""
+	if len(post.Tags) == 1 {
+		groupID = post.Tags[0]
+	}
+
+	short := post.Summary
+	if len(short) < 30 { // If it's too short, use description
+		short = strings.Split(post.Description, "\n")[0]
+	}
+
+	// Flags are added to a command only used to describe them
+	cmd := &cobra.Command{Use: name, Long: cleanFormat(post.Description)}
+	if schema := requestSchema(post); schema != nil {
+		addSchema(cmd, schema, true)
+		addVariants(cmd, schema)
+	}
+	addFileFlag(cmd, post)
+
+	c := IndexedCommand{
+		Name:    name,
+		Version: version,
+		Path:    path,
+		Short:   cleanFormat(short),
+		Long:    cmd.Long,
+		GroupID: groupID,
+		Flags:   flagDescriptors(cmd),
+		Post:    post,
+	}
+
+	// Already on the command help. Only the success response is used to render results.
+	c.Post.Summary = ""
+	c.Post.Description = ""
+	if resp, ok := post.Responses["200"]; ok {
+		c.Post.Responses = map[string]cli.Response{"200": resp}
+	} else {
+		c.Post.Responses = nil
+	}
+	return c
+}
+
+// flagDescriptors returns the descriptors of the local flags of a command, sorted by name.
+func flagDescriptors(cmd *cobra.Command) []FlagDescriptor {
+	flags := []FlagDescriptor{}
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		d := FlagDescriptor{
+			Name:     f.Name,
+			Type:     f.Value.Type(),
+			Usage:    f.Usage,
+			Default:  f.DefValue,
+			Required: slices.Contains(f.Annotations[cobra.BashCompOneRequiredFlag], "true"),
+			BodyPath: f.Annotations[bodyPathAnnotation],
+			Upload:   len(f.Annotations[uploadAnnotation]) > 0,
+		}
+		if fe, ok := f.Value.(*FlagEnum); ok {
+			d.Values = fe.GetValues()
+			d.Default = ""
+		}
+		flags = append(flags, d)
+	})
+	return flags
+}
+
+// addFlags adds the flags of an indexed command.
+func addFlags(cmd *cobra.Command, flags []FlagDescriptor) {
+	for _, d := range flags {
+		if d.Type == "string" {
+			cmd.Flags().String(d.Name, d.Default, d.Usage)
+		} else {
+			cmd.Flags().Var(newFlagValue(d), d.Name, d.Usage)
+		}
+		if len(d.Values) > 0 {
+			values := d.Values
+			cmd.RegisterFlagCompletionFunc(d.Name, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) { //nolint:errcheck
+				return values, cobra.ShellCompDirectiveDefault
+			})
+		}
+		if len(d.BodyPath) > 0 {
+			_ = cmd.Flags().SetAnnotation(d.Name, bodyPathAnnotation, d.BodyPath)
+		}
+		if d.Upload {
+			_ = cmd.Flags().SetAnnotation(d.Name, uploadAnnotation, []string{"true"})
+		}
+		if d.Required {
+			cmd.MarkFlagRequired(d.Name) //nolint:errcheck
+		}
+	}
+}
+
+// newFlagValue returns an empty value of the type of a flag. Unknown types take any value.
+func newFlagValue(d FlagDescriptor) pflag.Value {
+	switch d.Type {
+	case "string (enum)":
+		return NewFlagEnum(d.Name, d.Values)
+	case "integer":
+		return &FlagInteger{}
+	case "number":
+		return &FlagNumber{}
+	case "boolean":
+		return &FlagBool{}
+	case "json":
+		return &FlagJSON{}
+	case "map":
+		return &FlagMap{}
+	case "array":
+		return &FlagArray{}
+	case "integerArray":
+		return &FlagIntegerArray{}
+	case "numberArray":
+		return &FlagNumberArray{}
+	case "objectArray":
+		return &FlagObjectArray{}
+	default:
+		return &FlagAny{}
+	}
+}
+
+// AddIndexedCommands creates the commands of a service from its index.
+func (b *Builder) AddIndexedCommands(config *pangea.Config, svc string, idx *CommandIndex) {
+	for _, c := range idx.Commands {
+		b.addIndexedCommand(config, svc, c)
+	}
+}
+
+// LoadCommandIndex returns the saved index of a spec for this CLI version, or nil if there is none.
+func LoadCommandIndex(specHash string) *CommandIndex {
+	filename, err := cli.GetIndexFilename(specHash)
+	if err != nil {
+		return nil
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+
+	var idx CommandIndex
+	if err := json.Unmarshal(b, &idx); err != nil || idx.Format != commandIndexFormat || idx.SpecHash != specHash || idx.CLIVersion != cli.Version {
+		return nil
+	}
+	return &idx
+}
+
+// SaveCommandIndex saves an index to the cache folder.
+func SaveCommandIndex(idx *CommandIndex) error {
+	filename, err := cli.GetIndexFilename(idx.SpecHash)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b, 0644)
+}
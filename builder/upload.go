@@ -0,0 +1,345 @@
+package builder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	pangea "github.com/pangeacyber/pangea-go/pangea-sdk/v3/pangea"
+	"github.com/spf13/cobra"
+)
+
+// Default names of the multipart parts, used if the spec does not set them
+const (
+	defaultRequestPart = "request"
+	defaultFilePart    = "upload"
+)
+
+// Value of the `transfer_method` field for uploads on the request itself
+const transferMethodMultipart = "multipart"
+
+// Files are uploaded showing progress on stderr from this size
+const progressMinSize = 1 << 20
+
+const progressInterval = 200 * time.Millisecond
+
+// multipartParts returns the names of the JSON request part and the file part of an endpoint
+// that accepts multipart/form-data bodies. The file part is the one with binary format.
+func multipartParts(post cli.PathPost) (requestPart, filePart string, ok bool) {
+	content, ok := post.RequestBody.Content[cli.MultipartFormData]
+	if !ok {
+		return "", "", false
+	}
+
+	requestPart, filePart = defaultRequestPart, defaultFilePart
+	if content.Schema == nil {
+		return requestPart, filePart, true
+	}
+	for name, prop := range content.Schema.Properties {
+		if prop.Format == "binary" {
+			filePart = name
+		} else if prop.HasType("object") || len(prop.Properties) > 0 {
+			requestPart = name
+		}
+	}
+	return requestPart, filePart, true
+}
+
+// requestSchema returns the schema of the JSON request body of an endpoint. Endpoints that only
+// accept multipart/form-data bodies use the schema of their request part.
+func requestSchema(post cli.PathPost) *cli.Schema {
+	if content, ok := post.RequestBody.Content[cli.ApplicationJSON]; ok && content.Schema != nil {
+		return content.Schema
+	}
+
+	requestPart, _, ok := multipartParts(post)
+	if !ok {
+		return nil
+	}
+	content := post.RequestBody.Content[cli.MultipartFormData]
+	if content.Schema == nil {
+		return nil
+	}
+	prop, ok := content.Schema.Properties[requestPart]
+	if !ok {
+		return nil
+	}
+	return &cli.Schema{
+		Description: prop.Description,
+		Required:    prop.Required,
+		Properties:  prop.Properties,
+	}
+}
+
+// Flag annotation of the `file` flag added by addFileFlag, so it's told apart from request fields named `file`
+const uploadAnnotation = "pangea_upload"
+
+// addFileFlag adds the `file` flag to commands of endpoints that accept multipart/form-data bodies.
+func addFileFlag(cmd *cobra.Command, post cli.PathPost) {
+	if _, _, ok := multipartParts(post); !ok || cmd.Flags().Lookup(FlagFile) != nil {
+		return
+	}
+	cmd.Flags().String(FlagFile, "", "Path of the file to upload as part of a multipart/form-data request. Use '-' to read it from stdin.")
+	_ = cmd.Flags().SetAnnotation(FlagFile, uploadAnnotation, []string{"true"})
+}
+
+// Request fields set from the uploaded file by fillParams
+var uploadParams = []string{"transfer_method", "size", "sha256", "crc32c"}
+
+// relaxUploadFlags marks as optional the flags of the fields set from the uploaded file.
+func relaxUploadFlags(cmd *cobra.Command) {
+	for _, name := range uploadParams {
+		if cmd.Flags().Lookup(name) != nil {
+			_ = cmd.Flags().SetAnnotation(name, cobra.BashCompOneRequiredFlag, []string{"false"})
+		}
+	}
+}
+
+// upload is a file sent on a multipart/form-data request.
+type upload struct {
+	name     string
+	part     string
+	reader   io.Reader
+	size     int64
+	seekable bool
+}
+
+func (u *upload) Close() error {
+	if c, ok := u.reader.(io.Closer); ok && u.name != "-" {
+		return c.Close()
+	}
+	return nil
+}
+
+// openUpload opens the file set on the `file` flag, or returns nil if it's not set.
+func openUpload(cmd *cobra.Command, post cli.PathPost) (*upload, error) {
+	name, _ := CLIFlags(cmd).GetString(FlagFile)
+	if name == "" {
+		return nil, nil
+	}
+	_, part, _ := multipartParts(post)
+
+	if name == "-" {
+		return &upload{name: name, part: part, reader: os.Stdin, size: -1}, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file to upload: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to open file to upload: %w", err)
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, fmt.Errorf("%s is a directory", name)
+	}
+
+	return &upload{name: name, part: part, reader: f, size: info.Size(), seekable: true}, nil
+}
+
+// fillParams sets the transfer method and the size and hashes of the file on the fields of the
+// request the schema has and the user did not set. Hashes are not set for files read from stdin.
+func (u *upload) fillParams(schema *cli.Schema, data map[string]any) error {
+	if schema == nil {
+		return nil
+	}
+	has := func(name string) bool {
+		_, ok := schema.Properties[name]
+		_, set := data[name]
+		return ok && !set
+	}
+
+	if has("transfer_method") {
+		values := propEnumValues(schema.Properties["transfer_method"])
+		if len(values) == 0 || slices.Contains(values, transferMethodMultipart) {
+			data["transfer_method"] = transferMethodMultipart
+		}
+	}
+
+	if !u.seekable || !(has("size") || has("sha256") || has("crc32c")) {
+		return nil
+	}
+
+	sha := sha256.New()
+	crc := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	size, err := io.Copy(io.MultiWriter(sha, crc), u.reader)
+	if err != nil {
+		return fmt.Errorf("failed to read file to upload: %w", err)
+	}
+	if _, err := u.reader.(io.Seeker).Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read file to upload: %w", err)
+	}
+
+	if has("size") {
+		data["size"] = size
+	}
+	if has("sha256") {
+		data["sha256"] = hex.EncodeToString(sha.Sum(nil))
+	}
+	if has("crc32c") {
+		data["crc32c"] = hex.EncodeToString(crc.Sum(nil))
+	}
+	return nil
+}
+
+// newUploadRequest returns a multipart/form-data request with the JSON body on the request part
+// and the file on its own part. The file is streamed while the request is sent.
+func newUploadRequest(client *pangea.Client, url, requestPart string, data map[string]any, u *upload) (*http.Request, error) {
+	req, err := client.NewRequest("POST", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q`, requestPart))
+	header.Set("Content-Type", cli.ApplicationJSON)
+	pw, err := mw.CreatePart(header)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(pw).Encode(data); err != nil {
+		return nil, err
+	}
+	if _, err := mw.CreateFormFile(u.part, uploadFilename(u.name)); err != nil {
+		return nil, err
+	}
+	prefix := bytes.Clone(buf.Bytes())
+
+	buf.Reset()
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	suffix := bytes.Clone(buf.Bytes())
+
+	var file io.Reader = u.reader
+	if u.size >= progressMinSize && stderrIsTerminal() {
+		file = &progressReader{r: u.reader, w: os.Stderr, name: u.name, total: u.size}
+	}
+
+	req.Body = io.NopCloser(io.MultiReader(bytes.NewReader(prefix), file, bytes.NewReader(suffix)))
+	if u.size >= 0 {
+		req.ContentLength = int64(len(prefix)) + u.size + int64(len(suffix))
+	} else {
+		req.ContentLength = -1
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req, nil
+}
+
+// printUploadDryRun writes the request that would be sent to upload a file, with the token masked.
+// The file content is not read.
+func printUploadDryRun(w io.Writer, req *http.Request, requestPart string, u *upload) error {
+	req.Header.Set("Content-Type", cli.MultipartFormData)
+	if err := printDryRun(w, req); err != nil {
+		return err
+	}
+
+	size := "unknown size"
+	if u.size >= 0 {
+		size = formatBytes(u.size)
+	}
+	fmt.Fprintf(w, "\nParts: %s (JSON request above), %s (file %s, %s)\n", requestPart, u.part, u.name, size)
+	return nil
+}
+
+// printUploadCurl writes a curl command that uploads the file with the JSON request, using curl forms.
+func printUploadCurl(w io.Writer, req *http.Request, requestPart string, u *upload) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+
+	parts := []string{fmt.Sprintf("curl -X %s %s", req.Method, shellQuote(req.URL.String()))}
+	parts = append(parts, curlHeaders(req, "Content-Type")...)
+	parts = append(parts, "-F "+shellQuote(requestPart+"="+curlFormQuote(strings.TrimSpace(string(body)))+";type="+cli.ApplicationJSON))
+	parts = append(parts, "-F "+shellQuote(u.part+"=@"+curlFormQuote(u.name)))
+
+	fmt.Fprintln(w, strings.Join(parts, " \\\n  "))
+	return nil
+}
+
+// curlFormQuote quotes a value of a curl form field, so it could have commas and semicolons.
+func curlFormQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func uploadFilename(name string) string {
+	if name == "-" {
+		return "stdin"
+	}
+	return filepath.Base(name)
+}
+
+// progressReader writes the progress of the file being read to a terminal.
+type progressReader struct {
+	r     io.Reader
+	w     io.Writer
+	name  string
+	total int64
+	read  int64
+	last  time.Time
+	done  bool
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if p.done {
+		return n, err
+	}
+
+	p.done = errors.Is(err, io.EOF) || p.read >= p.total
+	if p.done || time.Since(p.last) >= progressInterval {
+		p.last = time.Now()
+		percent := 100
+		if p.total > 0 {
+			percent = int(p.read * 100 / p.total)
+		}
+		fmt.Fprintf(p.w, "\rUploading %s: %s / %s (%d%%)", p.name, formatBytes(p.read), formatBytes(p.total), percent)
+		if p.done {
+			fmt.Fprintln(p.w)
+		}
+	}
+	return n, err
+}
+
+// formatBytes returns a size in bytes with binary units, like `1.5 MiB`.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func stderrIsTerminal() bool {
+	fi, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
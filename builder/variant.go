@@ -0,0 +1,86 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// addVariants documents the variants of a request schema with a discriminator, and sets a help
+// function that only shows the flags of the variant selected with the discriminator flag.
+func addVariants(cmd *cobra.Command, schema *cli.Schema) {
+	prop := schema.DiscriminatorProperty()
+	names := schema.VariantNames()
+	if prop == "" || len(names) == 0 {
+		return
+	}
+
+	if f := cmd.Flags().Lookup(prop); f != nil {
+		f.Usage = mergeDescriptions(f.Usage, "Selects the request variant.")
+	}
+
+	cmd.Long = strings.TrimSpace(cmd.Long) + fmt.Sprintf("\n\nRequest variants are selected with '--%s': [%s]. Run '--%s <value> --help' to list the flags of each variant.", prop, strings.Join(names, " "), prop)
+	setVariantHelp(cmd, schema)
+}
+
+// setVariantHelp sets a help function that only shows the flags of the variant selected with the
+// discriminator flag, if the request schema has one.
+func setVariantHelp(cmd *cobra.Command, schema *cli.Schema) {
+	prop := schema.DiscriminatorProperty()
+	if prop == "" || len(schema.Variants()) == 0 {
+		return
+	}
+
+	cmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		f := cmd.Flags().Lookup(prop)
+		if f == nil || !f.Changed {
+			parentHelp(cmd, args)
+			return
+		}
+
+		variant, err := schema.Variant(f.Value.String())
+		if err != nil {
+			parentHelp(cmd, args)
+			return
+		}
+
+		printVariantHelp(cmd, prop, f.Value.String(), variant)
+	})
+}
+
+func parentHelp(cmd *cobra.Command, args []string) {
+	if cmd.HasParent() {
+		cmd.Parent().HelpFunc()(cmd, args)
+		return
+	}
+	_ = cmd.Usage()
+}
+
+func printVariantHelp(cmd *cobra.Command, prop, value string, variant *cli.Schema) {
+	out := cmd.OutOrStdout()
+
+	desc := cleanFormat(variant.Description)
+	if desc == "" {
+		desc = cmd.Short
+	}
+	fmt.Fprintf(out, "%s\n\nUsage:\n  %s\n\n", desc, cmd.UseLine())
+
+	fs := pflag.NewFlagSet(value, pflag.ContinueOnError)
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		top := strings.Split(f.Name, ".")[0]
+		if _, ok := variant.Properties[top]; ok || f.Name == "help" {
+			fs.AddFlag(f)
+		}
+	})
+
+	fmt.Fprintf(out, "Flags for %s '%s':\n%s", prop, value, fs.FlagUsages())
+	if len(variant.Required) > 0 {
+		fmt.Fprintf(out, "\nRequired: %s\n", strings.Join(variant.Required, ", "))
+	}
+	if cmd.HasAvailableInheritedFlags() {
+		fmt.Fprintf(out, "\nGlobal Flags:\n%s", cmd.InheritedFlags().FlagUsages())
+	}
+}
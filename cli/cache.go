@@ -5,11 +5,14 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type CacheData struct {
 	Paths            map[string]WorkspaceData `json:"paths"`
 	VersionAvailable map[string]*Release      `json:"version_available"`
+	// Key: encrypted secret cache file name. Value: SecretCacheData
+	Secrets map[string]SecretCacheData `json:"secrets,omitempty"`
 }
 
 // Key: path in lower case. Value: WorkspaceData
@@ -19,6 +22,16 @@ type WorkspaceData struct {
 	Remote string `json:"remote"`
 }
 
+// SecretCacheData is the metadata of the secrets of a workspace cached by `vault workspace run`. The
+// secrets are only saved encrypted, on their own file.
+type SecretCacheData struct {
+	Profile   string    `json:"profile"`
+	Workspace string    `json:"workspace"`
+	KeySource string    `json:"key_source"`
+	Count     int       `json:"count"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
 // Key: DaySinceEpoch. Value: Version available
 type VersionAvailable map[string]Release
 
@@ -96,6 +109,26 @@ func CacheSetRelease(r *Release) error {
 	return cacheSave(cd)
 }
 
+// CacheSetSecrets saves the metadata of a secret cache file, or removes it if data is nil.
+func CacheSetSecrets(name string, data *SecretCacheData) error {
+	cachePath, err := getCachePath()
+	if err != nil {
+		return err
+	}
+
+	cd, err := loadCacheData(cachePath)
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		delete(cd.Secrets, name)
+	} else {
+		cd.Secrets[name] = *data
+	}
+	return cacheSave(cd)
+}
+
 func loadCacheData(cachePath string) (*CacheData, error) {
 	jsonFile, err := os.Open(cachePath)
 	if err != nil {
@@ -122,6 +155,10 @@ func loadCacheData(cachePath string) (*CacheData, error) {
 		cd.VersionAvailable = make(map[string]*Release)
 	}
 
+	if cd.Secrets == nil {
+		cd.Secrets = make(map[string]SecretCacheData)
+	}
+
 	return &cd, nil
 }
 
@@ -0,0 +1,412 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Value set to scrubbed tokens and secrets on recorded cassettes
+const Redacted = "<redacted>"
+
+// Token used for requests replayed from a cassette, as recorded ones are scrubbed
+const replayToken = "pts_replay"
+
+const cassetteVersion = 1
+
+type CassetteMode int
+
+const (
+	CassetteOff CassetteMode = iota
+	// CassetteRecord sends requests and saves them with their responses to the cassette
+	CassetteRecord
+	// CassetteReplay answers requests with the responses saved on the cassette, without sending them
+	CassetteReplay
+)
+
+// Headers whose values are never saved to cassettes
+var secretHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+	"Set-Cookie":          true,
+}
+
+// Names of the JSON body fields whose values are scrubbed. Vault secrets, private keys, plain texts of
+// encrypt, decrypt and transform requests, and Vercel environment variables values are under these.
+var secretFields = map[string]bool{
+	"api_key":         true,
+	"password":        true,
+	"plain_text":      true,
+	"private_key":     true,
+	"secret":          true,
+	"structured_data": true,
+	"token":           true,
+	"value":           true,
+}
+
+// Fields only scrubbed on Vault bodies, as other APIs use these names for values that are not secret, like
+// Vercel for the names of environment variables.
+var vaultSecretFields = map[string]bool{
+	"key": true,
+}
+
+// Pangea tokens that could be on URLs or bodies, like on service token responses
+var tokenRegexp = regexp.MustCompile(`pt[a-z]_[a-z0-9]{32}`)
+
+// CassetteTransport is an http.RoundTripper that records requests and responses to a JSON file, or
+// replays them from it. Tokens and secret values are scrubbed before saving them, so cassettes could
+// be shared on bug reports or used by regression tests.
+//
+// Requests are matched by method, URL and body, on the order they were recorded, so repeated requests
+// like the polls of queued ones get their own responses.
+type CassetteTransport struct {
+	Base http.RoundTripper
+
+	mu       sync.Mutex
+	mode     CassetteMode
+	path     string
+	cassette cassette
+	used     []bool
+}
+
+type cassette struct {
+	Version      int                   `json:"version"`
+	CLIVersion   string                `json:"cli_version"`
+	RecordedAt   string                `json:"recorded_at"`
+	Interactions []cassetteInteraction `json:"interactions"`
+}
+
+type cassetteInteraction struct {
+	Request  cassetteRequest  `json:"request"`
+	Response cassetteResponse `json:"response"`
+}
+
+type cassetteRequest struct {
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	cassetteBody
+}
+
+type cassetteResponse struct {
+	Status  int                 `json:"status"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	cassetteBody
+}
+
+// cassetteBody holds JSON bodies as is so cassettes are readable, and any other as text.
+type cassetteBody struct {
+	JSON json.RawMessage `json:"json,omitempty"`
+	Text string          `json:"text,omitempty"`
+}
+
+// NewCassetteTransport returns a transport that records to path, sending requests with base, or that
+// replays path. Replayed cassettes are loaded right away.
+func NewCassetteTransport(base http.RoundTripper, mode CassetteMode, path string) (*CassetteTransport, error) {
+	t := &CassetteTransport{
+		Base: base,
+		mode: mode,
+		path: path,
+		cassette: cassette{
+			Version:      cassetteVersion,
+			CLIVersion:   Version,
+			RecordedAt:   time.Now().UTC().Format(time.RFC3339),
+			Interactions: []cassetteInteraction{},
+		},
+	}
+
+	switch mode {
+	case CassetteRecord:
+		// Fail early if the cassette could not be written
+		if err := t.save(); err != nil {
+			return nil, fmt.Errorf("failed to create cassette %s: %w", path, err)
+		}
+	case CassetteReplay:
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+		if err := json.Unmarshal(b, &t.cassette); err != nil {
+			return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+		}
+		if t.cassette.Version != cassetteVersion {
+			return nil, fmt.Errorf("unsupported cassette version %d", t.cassette.Version)
+		}
+		t.used = make([]bool, len(t.cassette.Interactions))
+	}
+	return t, nil
+}
+
+// The cassette set up by SetupCassette, if any
+var activeCassette *CassetteTransport
+
+// SetupCassette makes DefaultTransport record requests to the record file, or replay them from the
+// replay file. Only one of them could be set. It should be called before any request is sent.
+func SetupCassette(record, replay string) error {
+	if record != "" && replay != "" {
+		return errors.New("only one of record or replay cassettes could be set")
+	}
+
+	mode, path := CassetteRecord, record
+	if replay != "" {
+		mode, path = CassetteReplay, replay
+	}
+	if path == "" {
+		return nil
+	}
+
+	t, err := NewCassetteTransport(DefaultTransport.Base, mode, path)
+	if err != nil {
+		return err
+	}
+	DefaultTransport.Base = t
+	activeCassette = t
+	return nil
+}
+
+// GetCassetteMode returns if requests are being recorded or replayed.
+func GetCassetteMode() CassetteMode {
+	if activeCassette == nil {
+		return CassetteOff
+	}
+	return activeCassette.mode
+}
+
+// replayCredentials returns a token and the recorded domain of service, if requests to it are being
+// replayed. This way cassettes could be replayed without a CLI config.
+func replayCredentials(service string) (string, string, bool) {
+	if GetCassetteMode() != CassetteReplay {
+		return "", "", false
+	}
+	domain := activeCassette.domain(service)
+	if domain == "" {
+		return "", "", false
+	}
+	return replayToken, domain, true
+}
+
+// domain returns the domain, with scheme, of the first recorded request to service.
+func (t *CassetteTransport) domain(service string) string {
+	for _, i := range t.cassette.Interactions {
+		u, err := url.Parse(i.Request.URL)
+		if err != nil {
+			continue
+		}
+		if d, found := strings.CutPrefix(u.Host, service+"."); found {
+			if u.Scheme == "http" {
+				return "http://" + d
+			}
+			return d
+		}
+	}
+	return ""
+}
+
+func (t *CassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	creq := cassetteRequest{
+		Method:       req.Method,
+		URL:          scrubText(req.URL.String()),
+		Headers:      scrubHeaders(req.Header),
+		cassetteBody: newCassetteBody(reqBody, isVaultURL(req.URL)),
+	}
+
+	if t.mode == CassetteReplay {
+		return t.replay(req, creq)
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, cassetteInteraction{
+		Request: creq,
+		Response: cassetteResponse{
+			Status:       resp.StatusCode,
+			Headers:      scrubHeaders(resp.Header),
+			cassetteBody: newCassetteBody(respBody, isVaultURL(req.URL)),
+		},
+	})
+
+	// Saved on every request so nothing is lost if the command exits abruptly
+	if err := t.save(); err != nil {
+		return nil, fmt.Errorf("failed to save cassette: %w", err)
+	}
+	return resp, nil
+}
+
+func (t *CassetteTransport) replay(req *http.Request, creq cassetteRequest) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for n, i := range t.cassette.Interactions {
+		if t.used[n] || !i.Request.matches(creq) {
+			continue
+		}
+		t.used[n] = true
+
+		body := i.Response.bytes()
+		header := http.Header{}
+		for name, values := range i.Response.Headers {
+			header[name] = values
+		}
+		// Bodies could be changed when scrubbed
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", i.Response.Status, http.StatusText(i.Response.Status)),
+			StatusCode:    i.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded response for %s %s on cassette %s", req.Method, creq.URL, t.path)
+}
+
+func (t *CassetteTransport) save() error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(t.cassette); err != nil {
+		return err
+	}
+	return os.WriteFile(t.path, b.Bytes(), 0600)
+}
+
+func (r cassetteRequest) matches(o cassetteRequest) bool {
+	if r.Method != o.Method || r.URL != o.URL {
+		return false
+	}
+	if r.JSON == nil || o.JSON == nil {
+		return bytes.Equal(r.bytes(), o.bytes())
+	}
+
+	// Saved JSON bodies are indented
+	var rb, ob bytes.Buffer
+	return json.Compact(&rb, r.JSON) == nil && json.Compact(&ob, o.JSON) == nil && bytes.Equal(rb.Bytes(), ob.Bytes())
+}
+
+func (b cassetteBody) bytes() []byte {
+	if b.JSON != nil {
+		return b.JSON
+	}
+	return []byte(b.Text)
+}
+
+// newCassetteBody returns the scrubbed body, of a Vault request or response if vault is set. JSON bodies
+// are compacted so they're compared regardless of their format.
+func newCassetteBody(b []byte, vault bool) cassetteBody {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if len(b) == 0 || dec.Decode(&v) != nil || dec.More() {
+		return cassetteBody{Text: scrubText(string(b))}
+	}
+
+	var scrubbed bytes.Buffer
+	enc := json.NewEncoder(&scrubbed)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(scrubValue(v, vault)); err != nil {
+		return cassetteBody{Text: scrubText(string(b))}
+	}
+	return cassetteBody{JSON: bytes.TrimSpace(scrubbed.Bytes())}
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+func scrubHeaders(h http.Header) map[string][]string {
+	if len(h) == 0 {
+		return nil
+	}
+
+	out := make(map[string][]string, len(h))
+	for name, values := range h {
+		scrubbed := make([]string, 0, len(values))
+		for _, v := range values {
+			if secretHeaders[http.CanonicalHeaderKey(name)] {
+				v = Redacted
+			}
+			scrubbed = append(scrubbed, scrubText(v))
+		}
+		out[name] = scrubbed
+	}
+	return out
+}
+
+// scrubValue replaces the secret fields of a JSON value, objects included, and the Pangea tokens on its
+// strings.
+func scrubValue(v any, vault bool) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			if item != nil && (secretFields[k] || (vault && vaultSecretFields[k])) {
+				val[k] = Redacted
+				continue
+			}
+			val[k] = scrubValue(item, vault)
+		}
+		return val
+	case []any:
+		for n, item := range val {
+			val[n] = scrubValue(item, vault)
+		}
+		return val
+	case string:
+		return scrubText(val)
+	default:
+		return v
+	}
+}
+
+// isVaultURL returns whether u is an endpoint of Vault service, on `vault.<domain>`.
+func isVaultURL(u *url.URL) bool {
+	return strings.HasPrefix(u.Hostname(), "vault.")
+}
+
+func scrubText(s string) string {
+	return tokenRegexp.ReplaceAllString(s, Redacted)
+}
@@ -0,0 +1,127 @@
+package cli_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/stretchr/testify/assert"
+)
+
+const cassetteToken = "pts_abcdefghijklmnopqrstuvwxyz012345"
+
+func TestCassetteRecordReplay(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=1234")
+		if strings.Contains(string(b), "my_secret") {
+			_, _ = w.Write([]byte(`{"status": "Success", "result": {"secret": "my_secret", "count": 12345678901234567890}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status": "Success", "result": {"call": "second"}}`))
+	}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	send := func(client *http.Client, body string) (string, error) {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/secret/store", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+cassetteToken)
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	rec, err := cli.NewCassetteTransport(http.DefaultTransport, cli.CassetteRecord, path)
+	assert.NoError(t, err)
+	client := &http.Client{Transport: rec}
+
+	out, err := send(client, `{"secret": "my_secret", "name": "db"}`)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "my_secret")
+	_, err = send(client, `{"name": "other"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "my_secret")
+	assert.NotContains(t, string(b), cassetteToken)
+	assert.NotContains(t, string(b), "session=1234")
+	assert.Contains(t, string(b), "12345678901234567890")
+
+	play, err := cli.NewCassetteTransport(nil, cli.CassetteReplay, path)
+	assert.NoError(t, err)
+	client = &http.Client{Transport: play}
+
+	// Secret values are scrubbed on replayed requests too, so they match the recorded ones
+	out, err = send(client, `{"name": "db", "secret": "another_secret"}`)
+	assert.NoError(t, err)
+	assert.Contains(t, out, cli.Redacted)
+	out, err = send(client, `{"name": "other"}`)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "second")
+	assert.Equal(t, 2, calls)
+
+	// Each recorded response is replayed once
+	_, err = send(client, `{"name": "other"}`)
+	assert.ErrorContains(t, err, "no recorded response")
+}
+
+func TestCassetteReplayMissingFile(t *testing.T) {
+	_, err := cli.NewCassetteTransport(nil, cli.CassetteReplay, filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestCassetteScrubVaultFields(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.HasPrefix(r.Host, "vault.") {
+			_, _ = w.Write([]byte(`{"envs": [{"key": "DB_PASSWORD"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status": "Success", "result": {"plain_text": "ZGVjcnlwdGVk", "structured_data": {"field": "decrypted"}, "key": "a2V5"}}`))
+	}))
+	defer ts.Close()
+
+	// Requests go through the server as a proxy, as they do to the mock Pangea server
+	proxyURL, err := url.Parse(ts.URL)
+	assert.NoError(t, err)
+	base := &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec, err := cli.NewCassetteTransport(base, cli.CassetteRecord, path)
+	assert.NoError(t, err)
+	client := &http.Client{Transport: rec}
+
+	send := func(u, body string) {
+		resp, err := client.Post(u, "application/json", strings.NewReader(body))
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	send("http://vault.pangea.test/v1/key/decrypt", `{"id": "pvi_1", "cipher_text": "Y2lwaGVy"}`)
+	send("http://vault.pangea.test/v1/key/encrypt", `{"id": "pvi_1", "plain_text": "cGxhaW4="}`)
+	send("http://vault.pangea.test/v1/key/store", `{"type": "symmetric_key", "key": "c3ltbWV0cmlj"}`)
+	send("http://vercel.test/v10/projects/app/env", `{"key": "DB_PASSWORD", "value": "vercel_secret"}`)
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	for _, secret := range []string{"ZGVjcnlwdGVk", "decrypted", "a2V5", "cGxhaW4=", "c3ltbWV0cmlj", "vercel_secret"} {
+		assert.NotContains(t, string(b), secret)
+	}
+	assert.Contains(t, string(b), "Y2lwaGVy")
+	// Only Vault keys are secret
+	assert.Contains(t, string(b), "DB_PASSWORD")
+}
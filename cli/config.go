@@ -4,8 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pangeacyber/pangea-go/pangea-sdk/v3/pangea"
@@ -30,8 +34,14 @@ type ConfigFile struct {
 	Version  string             `mapstructure:"version"`
 	Profile  string             `mapstructure:"profile"`
 	Profiles map[string]Profile `mapstructure:"profiles"`
+	// Services loaded on each profile. See FilterServices for its format. All services are loaded on
+	// profiles without a list, unless the `default` profile has one.
+	Services map[string][]string `mapstructure:"services" json:"services,omitempty"`
 }
 
+// Environment variable with a comma separated list of the services to load. It overrides the config.
+const ServicesEnvVar = "PANGEA_CLI_SERVICES"
+
 var defaultConfig = ConfigFile{
 	Title:    "Pangea",
 	Version:  "v2.0",
@@ -45,7 +55,55 @@ func GetDefaultPangeaConfig() pangea.Config {
 		PollResultTimeout:  time.Second * 30,
 		QueuedRetryEnabled: true,
 		CustomUserAgent:    "pangea-cli/" + Version,
+		HTTPClient:         HTTPClient(),
+	}
+}
+
+// Environment variable that allows domains with `http://` scheme other than local ones to be reached
+// without TLS.
+const AllowHTTPEnvVar = "PANGEA_CLI_ALLOW_HTTP"
+
+// Domains already warned about their `http://` scheme, so each one is only reported once.
+var httpWarnings sync.Map
+
+// SetDomain sets the domain of a Pangea config. Domains with `http://` scheme are reached without TLS
+// if they're local test servers, like `localhost`, loopback addresses or `.test` hosts, or if
+// PANGEA_CLI_ALLOW_HTTP environment variable is set. Otherwise the scheme is ignored.
+func SetDomain(config *pangea.Config, domain string) {
+	host, insecure := strings.CutPrefix(domain, "http://")
+	config.Domain = strings.TrimPrefix(host, "https://")
+	config.Insecure = false
+	if !insecure {
+		return
+	}
+
+	switch {
+	case isLocalHost(host):
+		config.Insecure = true
+	case os.Getenv(AllowHTTPEnvVar) != "":
+		config.Insecure = true
+		if _, warned := httpWarnings.LoadOrStore(domain, true); !warned {
+			fmt.Fprintf(os.Stderr, "Warning: reaching %s without TLS.\n", domain)
+		}
+	default:
+		if _, warned := httpWarnings.LoadOrStore(domain, true); !warned {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring http:// scheme of %s, it's reached with TLS. Set %s to reach it without TLS.\n", domain, AllowHTTPEnvVar)
+		}
+	}
+}
+
+// isLocalHost returns if a domain, optionally with a port, is a loopback address or a `localhost` or
+// `.test` host.
+func isLocalHost(domain string) bool {
+	host := domain
+	if h, _, err := net.SplitHostPort(domain); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback()
 	}
+	return host == "localhost" || strings.HasSuffix(host, ".localhost") || host == "test" || strings.HasSuffix(host, ".test")
 }
 
 var ErrNoConfigFile = errors.New("pangea Token doesn't exist. Run `pangea login` to setup your CLI")
@@ -231,6 +289,10 @@ func getPriorityList(profile, service string) []ps {
 }
 
 func GetTokenAndDomain(service string) (string, string, error) {
+	if token, domain, ok := replayCredentials(service); ok {
+		return token, domain, nil
+	}
+
 	config, err := loadConfig()
 	if err != nil {
 		return "", "", err
@@ -244,6 +306,10 @@ func GetTokenAndDomain(service string) (string, string, error) {
 }
 
 func GetProfileTokenAndDomain(profile, service string) (string, string, error) {
+	if token, domain, ok := replayCredentials(service); ok {
+		return token, domain, nil
+	}
+
 	config, err := loadConfig()
 	if err != nil {
 		return "", "", err
@@ -436,6 +502,7 @@ func DeleteProfile(profileName string) error {
 	}
 
 	delete(config.Profiles, profileName)
+	delete(config.Services, profileName)
 	config.validate()
 	return config.save()
 }
@@ -457,3 +524,81 @@ func SelectProfile(profileName string) error {
 	config.Profile = profileName
 	return config.save()
 }
+
+// GetServices returns the services list of a profile, falling back to the one of the `default` profile.
+func (cf *ConfigFile) GetServices(profile string) []string {
+	if profile == "" {
+		profile = cf.Profile
+	}
+	if list, ok := cf.Services[profile]; ok {
+		return list
+	}
+	return cf.Services["default"]
+}
+
+// SaveServices sets the services list of a profile, or the current one if profile is empty. An empty
+// list enables all services.
+func SaveServices(profile string, services []string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if profile == "" {
+		profile = config.Profile
+	}
+	if config.Services == nil {
+		config.Services = map[string][]string{}
+	}
+	if len(services) == 0 {
+		delete(config.Services, profile)
+	} else {
+		config.Services[profile] = services
+	}
+	return config.save()
+}
+
+// GetEnabledServices returns the services to load, from PANGEA_CLI_SERVICES environment variable or the
+// current profile services list. All available services are enabled if none is set.
+func GetEnabledServices(available []string) []string {
+	if env := os.Getenv(ServicesEnvVar); env != "" {
+		return FilterServices(available, strings.Split(env, ","))
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return available
+	}
+	return FilterServices(available, config.GetServices(""))
+}
+
+// FilterServices applies a services list to the available ones. Services on the list are enabled, even
+// if they're not available, so new services could be used. Services prefixed with `-` are disabled. If
+// the list only disables services, they're removed from the available ones.
+func FilterServices(available, list []string) []string {
+	enabled := []string{}
+	disabled := map[string]bool{}
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if name, found := strings.CutPrefix(s, "-"); found {
+			disabled[name] = true
+		} else if s != "" && !slices.Contains(enabled, s) {
+			enabled = append(enabled, s)
+		}
+	}
+
+	if len(enabled) == 0 {
+		if len(disabled) == 0 {
+			return available
+		}
+		enabled = available
+	}
+
+	services := make([]string, 0, len(enabled))
+	for _, s := range enabled {
+		if !disabled[s] {
+			services = append(services, s)
+		}
+	}
+	return services
+}
@@ -0,0 +1,51 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/pangeacyber/pangea-go/pangea-sdk/v3/pangea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterServices(t *testing.T) {
+	available := []string{"vault", "embargo", "redact", "audit"}
+
+	assert.Equal(t, available, cli.FilterServices(available, nil))
+	assert.Equal(t, []string{"redact", "vault"}, cli.FilterServices(available, []string{"redact", " vault", "redact"}))
+	assert.Equal(t, []string{"vault", "redact"}, cli.FilterServices(available, []string{"-embargo", "-audit"}))
+	assert.Equal(t, []string{"vault"}, cli.FilterServices(available, []string{"vault", "audit", "-audit"}))
+	// Services not on the available list could be enabled
+	assert.Equal(t, []string{"prompt-guard"}, cli.FilterServices(available, []string{"prompt-guard"}))
+}
+
+func TestGetEnabledServicesEnv(t *testing.T) {
+	t.Setenv(cli.ServicesEnvVar, "vault,-redact,redact")
+	assert.Equal(t, []string{"vault"}, cli.GetEnabledServices([]string{"vault", "redact"}))
+}
+
+func TestSetDomainHTTP(t *testing.T) {
+	t.Setenv(cli.AllowHTTPEnvVar, "")
+	for domain, insecure := range map[string]bool{
+		"aws.us.pangea.cloud":          false,
+		"https://aws.us.pangea.cloud":  false,
+		"http://aws.us.pangea.cloud":   false,
+		"http://localhost:8000":        true,
+		"http://127.0.0.1:8000":        true,
+		"http://[::1]:8000":            true,
+		"http://pangea.test":           true,
+		"http://pangea.localhost":      true,
+		"http://pangea.test.pangea.io": false,
+	} {
+		var config pangea.Config
+		cli.SetDomain(&config, domain)
+		assert.Equal(t, insecure, config.Insecure, domain)
+		assert.NotContains(t, config.Domain, "://", domain)
+	}
+
+	t.Setenv(cli.AllowHTTPEnvVar, "1")
+	var config pangea.Config
+	cli.SetDomain(&config, "http://pangea.internal")
+	assert.True(t, config.Insecure)
+	assert.Equal(t, "pangea.internal", config.Domain)
+}
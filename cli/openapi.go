@@ -3,13 +3,10 @@ package cli
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"net/http"
-	"net/url"
 	"os"
-	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -32,118 +29,250 @@ type Path struct {
 }
 
 type PathPost struct {
-	ID              string              `json:"operationId"`
-	Summary         string              `json:"summary"`
-	Description     string              `json:"description"`
-	Tags            []string            `json:"tags"`
+	ID              string              `json:"operationId,omitempty"`
+	Summary         string              `json:"summary,omitempty"`
+	Description     string              `json:"description,omitempty"`
+	Tags            []string            `json:"tags,omitempty"`
 	RequestBody     RequestBody         `json:"requestBody"`
-	Responses       map[string]Response `json:"responses"`
-	XPangeaUISchema *XPangeaUISchema    `json:"x-pangea-ui-schema"`
+	Responses       map[string]Response `json:"responses,omitempty"`
+	XPangeaUISchema *XPangeaUISchema    `json:"x-pangea-ui-schema,omitempty"`
 }
 
 type XPangeaUISchema struct {
-	IsConfiguration *bool `json:"isConfiguration"`
+	IsConfiguration *bool `json:"isConfiguration,omitempty"`
 }
 
 type RequestBody struct {
-	Content Content `json:"content"`
+	Content Content `json:"content,omitempty"`
 }
 
 type Response struct {
-	Description string  `json:"description"`
-	Content     Content `json:"content"`
+	Description string  `json:"description,omitempty"`
+	Content     Content `json:"content,omitempty"`
 }
 
 type Content map[string]struct {
-	Schema *Schema `json:"schema"`
+	Schema *Schema `json:"schema,omitempty"`
 }
 
 type Schema struct {
-	Ref           string         `json:"$ref"`
-	OneOf         []Schema       `json:"oneOf"`
-	AnyOf         []Schema       `json:"anyOf"`
-	Discriminator *Discriminator `json:"discriminator"`
+	Ref           string         `json:"$ref,omitempty"`
+	AllOf         []Schema       `json:"allOf,omitempty"`
+	OneOf         []Schema       `json:"oneOf,omitempty"`
+	AnyOf         []Schema       `json:"anyOf,omitempty"`
+	Discriminator *Discriminator `json:"discriminator,omitempty"`
+
+	Title                string                `json:"title,omitempty"`
+	Description          string                `json:"description,omitempty"`
+	Required             []string              `json:"required,omitempty"`
+	Properties           Properties            `json:"properties,omitempty"`
+	AdditionalProperties *AdditionalProperties `json:"additionalProperties,omitempty"`
+}
+
+// UnmarshalJSON accepts a schema object or a reference string, as used on discriminator mappings.
+func (s *Schema) UnmarshalJSON(b []byte) error {
+	var ref string
+	if err := json.Unmarshal(b, &ref); err == nil {
+		*s = Schema{Ref: ref}
+		return nil
+	}
 
-	Title       string     `json:"title"`
-	Description string     `json:"description"`
-	Required    []string   `json:"required"`
-	Properties  Properties `json:"properties"`
+	type schema Schema
+	return json.Unmarshal(b, (*schema)(s))
 }
 
 type Discriminator struct {
-	PropertyName string            `json:"propertyName"`
-	Mapping      map[string]Schema `json:"mapping"`
+	PropertyName string            `json:"propertyName,omitempty"`
+	Mapping      map[string]Schema `json:"mapping,omitempty"`
 }
 
 type Properties map[string]Property
 
 type Property struct {
-	Ref string `json:"$ref"`
+	Ref   string     `json:"$ref,omitempty"`
+	AllOf []Property `json:"allOf,omitempty"`
+	OneOf []Property `json:"oneOf,omitempty"`
+	AnyOf []Property `json:"anyOf,omitempty"`
+
+	Type        SchemaType `json:"type,omitempty"`
+	Nullable    bool       `json:"nullable,omitempty"`
+	Default     any        `json:"default,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Format      string     `json:"format,omitempty"`
+	Pattern     string     `json:"pattern,omitempty"`
+	Enum        []any      `json:"enum,omitempty"`
+	Const       any        `json:"const,omitempty"`
+	Properties  Properties `json:"properties,omitempty"`
+	Required    []string   `json:"required,omitempty"`
+	Items       *Property  `json:"items,omitempty"`
+	Minimum     *float64   `json:"minimum,omitempty"`
+	Maximum     *float64   `json:"maximum,omitempty"`
+	MinLength   *int       `json:"minLength,omitempty"`
+	MaxLength   *int       `json:"maxLength,omitempty"`
+	MinItems    *int       `json:"minItems,omitempty"`
+	MaxItems    *int       `json:"maxItems,omitempty"`
+
+	AdditionalProperties *AdditionalProperties `json:"additionalProperties,omitempty"`
+}
+
+// AdditionalProperties is the `additionalProperties` of an object: whether fields not listed on its
+// properties are allowed, and the schema they should match if it's set.
+type AdditionalProperties struct {
+	Allowed bool
+	Schema  *Property
+}
+
+func (a *AdditionalProperties) UnmarshalJSON(b []byte) error {
+	var allowed bool
+	if err := json.Unmarshal(b, &allowed); err == nil {
+		*a = AdditionalProperties{Allowed: allowed}
+		return nil
+	}
+
+	var p Property
+	if err := json.Unmarshal(b, &p); err != nil {
+		return fmt.Errorf("invalid additionalProperties %s", string(b))
+	}
+	*a = AdditionalProperties{Allowed: true, Schema: &p}
+	return nil
+}
+
+func (a AdditionalProperties) MarshalJSON() ([]byte, error) {
+	if a.Schema != nil {
+		return json.Marshal(a.Schema)
+	}
+	return json.Marshal(a.Allowed)
+}
 
-	Type        json.RawMessage `json:"type"` // TODO: this can be a string or an array of strings
-	Default     any             `json:"default"`
-	Description string          `json:"description"`
-	Format      string          `json:"format"`
-	Enum        []any           `json:"enum"`
-	Const       any             `json:"const"`
+// AllowsField returns if a field not listed on the properties of an object is allowed. Fields are
+// allowed unless additionalProperties is false.
+func (a *AdditionalProperties) AllowsField() bool {
+	return a == nil || a.Allowed
 }
 
+// SchemaType is the `type` of a schema. It could be a single type or a list of them.
+type SchemaType []string
+
+func (t *SchemaType) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = SchemaType{s}
+		return nil
+	}
+
+	var ss []string
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return fmt.Errorf("invalid type %s", string(b))
+	}
+	*t = ss
+	return nil
+}
+
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// Types returns the list of types allowed by the property, including `null` if it's nullable.
+func (p Property) Types() []string {
+	types := slices.Clone([]string(p.Type))
+	if p.Nullable && len(types) > 0 && !slices.Contains(types, "null") {
+		types = append(types, "null")
+	}
+	return types
+}
+
+// NonNullTypes returns the list of types allowed by the property, except `null`.
+func (p Property) NonNullTypes() []string {
+	return slices.DeleteFunc(p.Types(), func(t string) bool {
+		return t == "null"
+	})
+}
+
+// HasType returns if the property allows a type.
+func (p Property) HasType(t string) bool {
+	return slices.Contains(p.Types(), t)
+}
+
+// Max depth of nested properties and items resolved. Recursive schemas are stopped on the references
+// being resolved, so it only bounds the size of deeply nested ones.
+const maxPropertiesDepth = 10
+
+// refSet holds the references being resolved, to stop on the ones that reference themselves.
+type refSet map[string]bool
+
 func (oa *OpenAPI) resolveReferences() error {
 	for name, path := range oa.Paths {
 		// request body
 		for mime, content := range path.Post.RequestBody.Content {
-			err := oa.resolveSchema(&content.Schema)
-			if err != nil {
-				return nil
+			if content.Schema == nil {
+				continue
 			}
-
-			err = oa.resolveDiscriminator(content.Schema.Discriminator)
+			err := oa.resolveSchema(&content.Schema, refSet{})
 			if err != nil {
-				return nil
-			}
-
-			err = oa.resolvePropertiesReferences(content.Schema.Properties)
-			if err != nil {
-				return err
+				return fmt.Errorf("%s request: %w", name, err)
 			}
 			oa.Paths[name].Post.RequestBody.Content[mime] = content
 		}
 		// responses
-		for mime, response := range path.Post.Responses {
+		for status, response := range path.Post.Responses {
 			for mime, content := range response.Content {
-				err := oa.resolveSchema(&content.Schema)
-				if err != nil {
-					return nil
+				if content.Schema == nil {
+					continue
 				}
-				response.Content[mime] = content
-				err = oa.resolvePropertiesReferences(content.Schema.Properties)
+				err := oa.resolveSchema(&content.Schema, refSet{})
 				if err != nil {
-					return err
+					return fmt.Errorf("%s %s response: %w", name, status, err)
 				}
+				response.Content[mime] = content
 			}
-			path.Post.Responses[mime] = response
+			path.Post.Responses[status] = response
 		}
 	}
 	return nil
 }
 
-func (oa *OpenAPI) resolveSchema(source **Schema) error {
-	if (*source).Ref != "" {
+// resolveSchema replaces the references of a schema by their target, and merges its allOf schemas.
+// A reference to a schema that is being resolved, as a subtype that extends its base, only gets the
+// target's own fields and properties, without the schemas it extends or its variants.
+func (oa *OpenAPI) resolveSchema(source **Schema, refs refSet) error {
+	if ref := (*source).Ref; ref != "" {
 		var target Schema
-		err := oa.schemaRef((*source).Ref, &target)
+		err := oa.schemaRef(ref, &target)
 		if err != nil {
 			return err
 		}
+		if refs[ref] {
+			*source = &Schema{
+				Title:       target.Title,
+				Description: target.Description,
+				Required:    target.Required,
+				Properties:  target.Properties,
+			}
+			return oa.resolvePropertiesReferences((*source).Properties, refs)
+		}
+
+		refs[ref] = true
+		defer delete(refs, ref)
 		*source = &target
-		err = oa.resolveSchema(source)
+		return oa.resolveSchema(source, refs)
+	}
+
+	for _, sch := range (*source).AllOf {
+		psch := &sch
+		err := oa.resolveSchema(&psch, refs)
 		if err != nil {
 			return err
 		}
+		mergeSchema(*source, psch)
 	}
+	(*source).AllOf = nil
 
 	for i, sch := range (*source).OneOf {
 		psch := &sch
-		err := oa.resolveSchema(&psch)
+		err := oa.resolveSchema(&psch, refs)
 		if err != nil {
 			return err
 		}
@@ -152,70 +281,262 @@ func (oa *OpenAPI) resolveSchema(source **Schema) error {
 
 	for i, sch := range (*source).AnyOf {
 		psch := &sch
-		err := oa.resolveSchema(&psch)
+		err := oa.resolveSchema(&psch, refs)
 		if err != nil {
 			return err
 		}
 		(*source).AnyOf[i] = *psch
 	}
 
-	err := oa.resolvePropertiesReferences((*source).Properties)
+	err := oa.resolveDiscriminator((*source).Discriminator, refs)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if ap := (*source).AdditionalProperties; ap != nil && ap.Schema != nil {
+		err := oa.resolveProperty(ap.Schema, 0, refs)
+		if err != nil {
+			return fmt.Errorf("additionalProperties: %w", err)
+		}
+	}
+
+	return oa.resolvePropertiesReferences((*source).Properties, refs)
 }
 
-func (oa *OpenAPI) resolveDiscriminator(d *Discriminator) error {
+// mergeSchema adds the properties, required fields and variants of an allOf schema to dst.
+func mergeSchema(dst, src *Schema) {
+	if dst.Properties == nil && len(src.Properties) > 0 {
+		dst.Properties = Properties{}
+	}
+	for name, prop := range src.Properties {
+		if _, ok := dst.Properties[name]; !ok {
+			dst.Properties[name] = prop
+		}
+	}
+	for _, r := range src.Required {
+		if !slices.Contains(dst.Required, r) {
+			dst.Required = append(dst.Required, r)
+		}
+	}
+	dst.OneOf = append(dst.OneOf, src.OneOf...)
+	dst.AnyOf = append(dst.AnyOf, src.AnyOf...)
+	if dst.Discriminator == nil {
+		dst.Discriminator = src.Discriminator
+	}
+	if dst.AdditionalProperties == nil {
+		dst.AdditionalProperties = src.AdditionalProperties
+	}
+	if dst.Title == "" {
+		dst.Title = src.Title
+	}
+	if dst.Description == "" {
+		dst.Description = src.Description
+	}
+}
+
+func (oa *OpenAPI) resolveDiscriminator(d *Discriminator, refs refSet) error {
 	if d == nil {
 		return nil
 	}
 
 	for k, v := range d.Mapping {
 		sch := &v
-		err := oa.resolveSchema(&sch)
+		err := oa.resolveSchema(&sch, refs)
 		if err != nil {
-			continue
+			return fmt.Errorf("discriminator mapping '%s': %w", k, err)
 		}
-		d.Mapping[k] = v
+		d.Mapping[k] = *sch
 	}
 	return nil
 }
 
-func (oa *OpenAPI) resolvePropertiesReferences(props Properties) error {
+func (oa *OpenAPI) resolvePropertiesReferences(props Properties, refs refSet) error {
+	return oa.resolveNestedPropertiesReferences(props, 0, refs)
+}
+
+func (oa *OpenAPI) resolveNestedPropertiesReferences(props Properties, depth int, refs refSet) error {
 	for k, v := range props {
-		if v.Ref != "" {
-			var prop Property
-			err := oa.schemaRef(v.Ref, &prop)
+		err := oa.resolveProperty(&v, depth, refs)
+		if err != nil {
+			return fmt.Errorf("property '%s': %w", k, err)
+		}
+		props[k] = v
+	}
+	return nil
+}
+
+// resolveProperty replaces the references of a property by their target, and merges its allOf
+// schemas. Its oneOf and anyOf schemas are kept for validation, and their types are set on the
+// property. If only one of them is not null, it's merged on the property and the property is nullable.
+// A reference to a schema that is being resolved only gets the target's type, so the value of a
+// recursive property is taken as it is.
+func (oa *OpenAPI) resolveProperty(p *Property, depth int, refs refSet) error {
+	if ref := p.Ref; ref != "" {
+		var target Property
+		err := oa.schemaRef(ref, &target)
+		if err != nil {
+			return err
+		}
+		// Fields set next to the reference override the target ones
+		p.Ref = ""
+		if refs[ref] {
+			target = recursiveProperty(target)
+		} else {
+			if depth >= maxPropertiesDepth {
+				return fmt.Errorf("ref '%s' nested deeper than %d levels", ref, maxPropertiesDepth)
+			}
+			refs[ref] = true
+			defer delete(refs, ref)
+		}
+		p.AllOf = append([]Property{target}, p.AllOf...)
+	}
+
+	for i := range p.AllOf {
+		err := oa.resolveProperty(&p.AllOf[i], depth, refs)
+		if err != nil {
+			return err
+		}
+		mergeProperty(p, p.AllOf[i])
+	}
+	p.AllOf = nil
+
+	for _, variants := range [][]Property{p.OneOf, p.AnyOf} {
+		for i := range variants {
+			err := oa.resolveProperty(&variants[i], depth, refs)
 			if err != nil {
 				return err
 			}
-			props[k] = prop
 		}
 	}
-	return nil
+	if variants := append(slices.Clone(p.OneOf), p.AnyOf...); len(variants) > 0 && len(p.Type) == 0 {
+		nonNull := []Property{}
+		for _, v := range variants {
+			if len(v.Type) == 1 && v.Type[0] == "null" {
+				p.Nullable = true
+				continue
+			}
+			nonNull = append(nonNull, v)
+			for _, t := range v.Types() {
+				if !slices.Contains(p.Type, t) {
+					p.Type = append(p.Type, t)
+				}
+			}
+		}
+		if len(nonNull) == 1 {
+			p.OneOf, p.AnyOf, p.Type = nil, nil, nil
+			mergeProperty(p, nonNull[0])
+		}
+	}
+
+	if p.Items != nil {
+		err := oa.resolveProperty(p.Items, depth+1, refs)
+		if err != nil {
+			return fmt.Errorf("items: %w", err)
+		}
+	}
+	if p.AdditionalProperties != nil && p.AdditionalProperties.Schema != nil {
+		err := oa.resolveProperty(p.AdditionalProperties.Schema, depth+1, refs)
+		if err != nil {
+			return fmt.Errorf("additionalProperties: %w", err)
+		}
+	}
+	return oa.resolveNestedPropertiesReferences(p.Properties, depth+1, refs)
+}
+
+// recursiveProperty returns the type and description of a property that references itself.
+func recursiveProperty(p Property) Property {
+	r := Property{Type: p.Type, Nullable: p.Nullable, Description: p.Description}
+	if len(r.Type) == 0 && len(p.Properties) > 0 {
+		r.Type = SchemaType{"object"}
+	}
+	return r
+}
+
+// mergeProperty sets the fields of an allOf schema that are not set on dst.
+func mergeProperty(dst *Property, src Property) {
+	if len(dst.Type) == 0 {
+		dst.Type = src.Type
+		dst.Nullable = dst.Nullable || src.Nullable
+	}
+	if dst.Default == nil {
+		dst.Default = src.Default
+	}
+	if dst.Description == "" {
+		dst.Description = src.Description
+	}
+	if dst.Format == "" {
+		dst.Format = src.Format
+	}
+	if dst.Pattern == "" {
+		dst.Pattern = src.Pattern
+	}
+	if dst.Enum == nil {
+		dst.Enum = src.Enum
+	}
+	if dst.Const == nil {
+		dst.Const = src.Const
+	}
+	if dst.Items == nil {
+		dst.Items = src.Items
+	}
+	if dst.AdditionalProperties == nil {
+		dst.AdditionalProperties = src.AdditionalProperties
+	}
+	if dst.OneOf == nil && dst.AnyOf == nil {
+		dst.OneOf, dst.AnyOf = src.OneOf, src.AnyOf
+	}
+	for _, limit := range []struct{ dst, src **float64 }{{&dst.Minimum, &src.Minimum}, {&dst.Maximum, &src.Maximum}} {
+		if *limit.dst == nil {
+			*limit.dst = *limit.src
+		}
+	}
+	for _, limit := range []struct{ dst, src **int }{
+		{&dst.MinLength, &src.MinLength}, {&dst.MaxLength, &src.MaxLength},
+		{&dst.MinItems, &src.MinItems}, {&dst.MaxItems, &src.MaxItems},
+	} {
+		if *limit.dst == nil {
+			*limit.dst = *limit.src
+		}
+	}
+
+	if dst.Properties == nil && len(src.Properties) > 0 {
+		dst.Properties = Properties{}
+	}
+	for name, prop := range src.Properties {
+		if _, ok := dst.Properties[name]; !ok {
+			dst.Properties[name] = prop
+		}
+	}
+	for _, r := range src.Required {
+		if !slices.Contains(dst.Required, r) {
+			dst.Required = append(dst.Required, r)
+		}
+	}
 }
 
 func (oa *OpenAPI) schemaRef(r string, val any) error {
 	p := strings.Split(r, "#")
-	if p[0] != oa.url {
-		return errors.New("ref not allowed")
+	if len(p) != 2 {
+		return fmt.Errorf("invalid ref '%s'", r)
+	}
+	// Allow local references and references to this same document
+	if p[0] != "" && p[0] != oa.url {
+		return fmt.Errorf("ref '%s' not allowed", r)
 	}
 	if !strings.HasPrefix(p[1], "/components/schemas/") {
-		return errors.New("ref not allowed")
+		return fmt.Errorf("ref '%s' not allowed", r)
 	}
 
 	pp := strings.Split(p[1], "/")
 	targetName := pp[len(pp)-1]
 	d, ok := oa.Components.Schemas[targetName]
 	if !ok {
-		return errors.New("schema not found")
+		return fmt.Errorf("schema '%s' not found", targetName)
 	}
 
 	err := json.Unmarshal(d, val)
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid schema '%s': %w", targetName, err)
 	}
 
 	return nil
@@ -251,25 +572,9 @@ func LoadReader(r io.Reader, url string) (*OpenAPI, error) {
 }
 
 func LoadURL(u string) (*OpenAPI, error) {
-	data := LoadFromCache(u)
-	if data == nil {
-		fmt.Fprintf(os.Stderr, "Downloading from %s...\n", u)
-		resp, err := http.Get(u)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		data, err = io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		err = SaveToCache(u, data)
-		if err == nil {
-			fmt.Fprintf(os.Stderr, "Downloaded. Saved to cache.\n")
-		} else {
-			fmt.Fprintf(os.Stderr, "Downloaded but failed to save to cache.\n")
-		}
+	data, err := LoadSpec(u)
+	if err != nil {
+		return nil, err
 	}
 
 	reader := bytes.NewReader(data)
@@ -284,79 +589,3 @@ func Unmarshal(s []byte) (*OpenAPI, error) {
 	}
 	return &oapi, nil
 }
-
-func GetCacheFilename(u string) (string, error) {
-	pu, err := url.Parse(u)
-	if err != nil {
-		return "", err
-	}
-
-	t := GetDaySinceEpoch()
-
-	cacheDir, err := GetCacheFolder()
-	if err != nil {
-		return "", err
-	}
-
-	name := strings.ReplaceAll(fmt.Sprintf("%s_%s", pu.Host, pu.Path), "/", "_")
-	path := filepath.Join(cacheDir, t, name)
-	return path, nil
-}
-
-func LoadFromCache(u string) []byte {
-	filename, err := GetCacheFilename(u)
-	if err != nil {
-		return nil
-	}
-
-	// Read the entire file into a byte slice
-	fileContent, err := os.ReadFile(filename)
-	if err != nil {
-		return nil
-	}
-
-	return fileContent
-}
-
-func SaveToCache(u string, data []byte) error {
-	filename, err := GetCacheFilename(u)
-	if err != nil {
-		return err
-	}
-
-	dir := filepath.Dir(filename)
-
-	// Check if the directory exists
-	_, err = os.Stat(dir)
-	if os.IsNotExist(err) {
-		// Directory does not exist, create it along with any necessary parent directories
-		err := os.MkdirAll(dir, 0755)
-		if err != nil {
-			return err
-		}
-	} else if err != nil {
-		// Other error occurred while checking directory existence
-		return err
-	}
-
-	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	_, err = file.Write(data)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func RemoveCachedFileFromURL(url string) error {
-	filename, err := GetCacheFilename(url)
-	if err != nil {
-		return err
-	}
-	return os.Remove(filename)
-}
@@ -0,0 +1,313 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/TylerBrock/colorjson"
+	"go.yaml.in/yaml/v3"
+)
+
+// Output formats supported by the `--output` flag
+const (
+	OutputJSON        = "json"
+	OutputJSONCompact = "json-compact"
+	OutputYAML        = "yaml"
+	OutputTable       = "table"
+	OutputCSV         = "csv"
+	OutputRaw         = "raw"
+)
+
+// RenderOptions are the hints a renderer could use to format a response.
+type RenderOptions struct {
+	// Color enables colorized output. Renderers that do not support colors ignore it.
+	Color bool
+
+	// Fields is the list of fields declared by the response schema, in the order they should be printed.
+	Fields []string
+
+	// ListField is the name of the field holding the list of results, if the response schema has one.
+	ListField string
+
+	// ListColumns is the list of columns of each item in the list of results, if they are known.
+	ListColumns []string
+}
+
+// Renderer writes a response to w in a particular format.
+type Renderer func(w io.Writer, data any, opts RenderOptions) error
+
+var renderers = map[string]Renderer{
+	OutputJSON:        renderJSON,
+	OutputJSONCompact: renderJSONCompact,
+	OutputYAML:        renderYAML,
+	OutputTable:       renderTable,
+	OutputCSV:         renderCSV,
+	// raw prints the full Pangea envelope, so it's up to the caller to provide it as data
+	OutputRaw: renderJSON,
+}
+
+// RegisterRenderer adds a new output format or replaces an existing one.
+func RegisterRenderer(format string, r Renderer) {
+	renderers[format] = r
+}
+
+// OutputFormats returns the sorted list of available output formats.
+func OutputFormats() []string {
+	formats := make([]string, 0, len(renderers))
+	for f := range renderers {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Render writes data to w using the renderer registered for format.
+func Render(w io.Writer, format string, data any, opts RenderOptions) error {
+	if err := ValidateOutputFormat(format); err != nil {
+		return err
+	}
+
+	data, err := normalize(data)
+	if err != nil {
+		return err
+	}
+	return renderers[format](w, data, opts)
+}
+
+// ValidateOutputFormat returns an error if there is no renderer registered for format.
+func ValidateOutputFormat(format string) error {
+	if _, ok := renderers[format]; !ok {
+		return fmt.Errorf("invalid output format '%s'. Possible values: [%s]", format, strings.Join(OutputFormats(), " "))
+	}
+	return nil
+}
+
+// normalize converts data to the generic types produced by json.Unmarshal, so renderers only
+// have to deal with maps, slices, strings, numbers, booleans and nil.
+func normalize(data any) (any, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ColorEnabled returns true if stdout is a terminal and NO_COLOR is not set.
+func ColorEnabled() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+
+	fi, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+func renderJSON(w io.Writer, data any, opts RenderOptions) error {
+	f := colorjson.NewFormatter()
+	f.Indent = 2
+	f.DisabledColor = !opts.Color
+
+	b, err := f.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+func renderJSONCompact(w io.Writer, data any, opts RenderOptions) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+func renderYAML(w io.Writer, data any, opts RenderOptions) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(data)
+}
+
+func renderTable(w io.Writer, data any, opts RenderOptions) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	if rows, ok := findList(data, opts.ListField); ok {
+		columns := listColumns(rows, opts.ListColumns)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(rowValues(row, columns), "\t"))
+		}
+		return tw.Flush()
+	}
+
+	// List of values, usually the output of a query. One per line.
+	if list, ok := data.([]any); ok {
+		for _, v := range list {
+			fmt.Fprintln(tw, cellValue(v))
+		}
+		return tw.Flush()
+	}
+
+	obj, ok := data.(map[string]any)
+	if !ok {
+		fmt.Fprintln(tw, cellValue(data))
+		return tw.Flush()
+	}
+
+	fmt.Fprintln(tw, "FIELD\tVALUE")
+	for _, k := range orderedKeys(obj, opts.Fields) {
+		fmt.Fprintf(tw, "%s\t%s\n", k, cellValue(obj[k]))
+	}
+	return tw.Flush()
+}
+
+func renderCSV(w io.Writer, data any, opts RenderOptions) error {
+	rows, ok := findList(data, opts.ListField)
+	if !ok {
+		return errors.New("csv output is only available for list results")
+	}
+
+	cw := csv.NewWriter(w)
+	columns := listColumns(rows, opts.ListColumns)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := cw.Write(rowValues(row, columns)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// findList looks for the list of results in data. It could be data itself, the field named
+// listField or the only field of data that is a list of objects.
+func findList(data any, listField string) ([]map[string]any, bool) {
+	if rows, ok := toRows(data); ok {
+		return rows, true
+	}
+
+	obj, ok := data.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+
+	if listField != "" {
+		return toRows(obj[listField])
+	}
+
+	var found []map[string]any
+	count := 0
+	for _, v := range obj {
+		if rows, ok := toRows(v); ok {
+			found = rows
+			count++
+		}
+	}
+	return found, count == 1
+}
+
+func toRows(data any) ([]map[string]any, bool) {
+	list, ok := data.([]any)
+	if !ok {
+		return nil, false
+	}
+
+	rows := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		row, ok := item.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		rows = append(rows, row)
+	}
+	return rows, true
+}
+
+func listColumns(rows []map[string]any, columns []string) []string {
+	if len(columns) > 0 {
+		return columns
+	}
+
+	keys := map[string]bool{}
+	for _, row := range rows {
+		for k := range row {
+			keys[k] = true
+		}
+	}
+
+	columns = make([]string, 0, len(keys))
+	for k := range keys {
+		columns = append(columns, k)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func rowValues(row map[string]any, columns []string) []string {
+	values := make([]string, 0, len(columns))
+	for _, c := range columns {
+		values = append(values, cellValue(row[c]))
+	}
+	return values
+}
+
+// orderedKeys returns the keys of obj, first the ones in fields and then the rest sorted.
+func orderedKeys(obj map[string]any, fields []string) []string {
+	keys := make([]string, 0, len(obj))
+	seen := map[string]bool{}
+	for _, f := range fields {
+		if _, ok := obj[f]; ok && !seen[f] {
+			keys = append(keys, f)
+			seen[f] = true
+		}
+	}
+
+	rest := []string{}
+	for k := range obj {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+func cellValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case map[string]any, []any:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,58 @@
+package cli_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderCSV(t *testing.T) {
+	data := map[string]any{
+		"count": 2,
+		"items": []any{
+			map[string]any{"id": "pvi_1", "name": "first"},
+			map[string]any{"id": "pvi_2", "name": "second", "tags": []any{"a", "b"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := cli.Render(&buf, cli.OutputCSV, data, cli.RenderOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "id,name,tags\npvi_1,first,\npvi_2,second,\"[\"\"a\"\",\"\"b\"\"]\"\n", buf.String())
+}
+
+func TestRenderCSVNoList(t *testing.T) {
+	var buf bytes.Buffer
+	err := cli.Render(&buf, cli.OutputCSV, map[string]any{"id": "pvi_1"}, cli.RenderOptions{})
+	assert.Error(t, err)
+}
+
+func TestRenderTableObject(t *testing.T) {
+	var buf bytes.Buffer
+	err := cli.Render(&buf, cli.OutputTable, map[string]any{"version": 1, "id": "pvi_1"}, cli.RenderOptions{Fields: []string{"id", "version"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "FIELD    VALUE\nid       pvi_1\nversion  1\n", buf.String())
+}
+
+func TestRenderJSONCompact(t *testing.T) {
+	var buf bytes.Buffer
+	err := cli.Render(&buf, cli.OutputJSONCompact, map[string]any{"id": "pvi_1"}, cli.RenderOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"id\":\"pvi_1\"}\n", buf.String())
+}
+
+func TestRenderJSONNoColor(t *testing.T) {
+	var buf bytes.Buffer
+	err := cli.Render(&buf, cli.OutputJSON, map[string]any{"id": "pvi_1", "count": 2}, cli.RenderOptions{Color: false})
+	assert.NoError(t, err)
+	assert.NotContains(t, buf.String(), "\x1b[")
+	assert.Equal(t, "{\n  \"count\": 2,\n  \"id\": \"pvi_1\"\n}\n", buf.String())
+}
+
+func TestRenderInvalidFormat(t *testing.T) {
+	var buf bytes.Buffer
+	err := cli.Render(&buf, "xml", map[string]any{}, cli.RenderOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+// Query applies a JMESPath expression to data and returns its result.
+// An empty expression returns data unchanged.
+func Query(expression string, data any) (any, error) {
+	if expression == "" {
+		return data, nil
+	}
+
+	// JMESPath works over the generic types returned by json.Unmarshal
+	data, err := normalize(data)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := jmespath.Search(expression, data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query '%s': %w", expression, err)
+	}
+	return r, nil
+}
+
+// ValidateQuery returns an error if expression is not a valid JMESPath expression.
+func ValidateQuery(expression string) error {
+	if expression == "" {
+		return nil
+	}
+
+	_, err := jmespath.Compile(expression)
+	if err != nil {
+		return fmt.Errorf("invalid query '%s': %w", expression, err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery(t *testing.T) {
+	data := map[string]any{
+		"items": []map[string]any{
+			{"id": "pvi_1", "name": "first"},
+			{"id": "pvi_2", "name": "second"},
+		},
+	}
+
+	r, err := cli.Query("items[].name", data)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"first", "second"}, r)
+
+	r, err = cli.Query("", data)
+	assert.NoError(t, err)
+	assert.Equal(t, data, r)
+
+	_, err = cli.Query("items[", data)
+	assert.Error(t, err)
+}
@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sources of cached specs
+const (
+	SpecSourceNetwork  = "network"
+	SpecSourceBundled  = "bundled"
+	SpecSourceImported = "imported"
+)
+
+// Specs bundled with the CLI, used when they could not be downloaded nor found on cache. Release builds
+// fill the folder running dev/bundle-specs.sh.
+//
+//go:embed bundle
+var bundleFS embed.FS
+
+// SpecBundle holds OpenAPI specs by service name. Bundles are folders or tar files with a
+// `<service>.json` spec file for each service.
+type SpecBundle map[string][]byte
+
+// ReadSpecBundle reads the specs of a bundle. Files that aren't JSON are skipped, and invalid specs
+// fail the whole bundle.
+func ReadSpecBundle(fsys fs.FS) (SpecBundle, error) {
+	bundle := SpecBundle{}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(p) != ".json" {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		return bundle.add(p, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// ReadSpecBundleFile reads the specs of a bundle on a folder, or a tar file optionally gzipped.
+func ReadSpecBundleFile(name string) (SpecBundle, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return ReadSpecBundle(os.DirFS(name))
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	bundle := SpecBundle{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg || path.Ext(hdr.Name) != ".json" {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		if err := bundle.add(hdr.Name, data); err != nil {
+			return nil, err
+		}
+	}
+	return bundle, nil
+}
+
+// Services returns the sorted names of the services on the bundle.
+func (b SpecBundle) Services() []string {
+	services := make([]string, 0, len(b))
+	for svc := range b {
+		services = append(services, svc)
+	}
+	sort.Strings(services)
+	return services
+}
+
+func (b SpecBundle) add(name string, data []byte) error {
+	var spec struct {
+		OpenAPI string          `json:"openapi"`
+		Paths   json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil || spec.OpenAPI == "" || spec.Paths == nil {
+		return fmt.Errorf("%s is not an OpenAPI spec", name)
+	}
+
+	svc := strings.TrimSuffix(path.Base(name), ".json")
+	if _, ok := b[svc]; ok {
+		return fmt.Errorf("%s spec is duplicated", svc)
+	}
+	b[svc] = data
+	return nil
+}
+
+// BundledSpec returns the spec of a service bundled with the CLI, or nil if there is none.
+func BundledSpec(svc string) []byte {
+	data, err := bundleFS.ReadFile(path.Join("bundle", svc+".json"))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// ImportSpec saves a spec to cache as the one on the URL. Imported specs are used until they're
+// refreshed, imported again or the cache is cleaned, even if they expire and can't be revalidated.
+func ImportSpec(u string, data []byte) (*SpecCacheEntry, error) {
+	entry := newSpecCacheEntry(u, data, SpecSourceImported)
+	if err := saveCacheEntry(u, entry, data); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// SpecVersion returns the `info.version` field of a spec.
+func SpecVersion(data []byte) string {
+	var spec struct {
+		Info struct {
+			Version string `json:"version"`
+		} `json:"info"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return ""
+	}
+	return spec.Info.Version
+}
+
+// Origin describes where the spec comes from, like `version 1.2.0, bundled with the CLI`.
+func (e *SpecCacheEntry) Origin() string {
+	var origin string
+	switch e.Source {
+	case SpecSourceBundled:
+		origin = "bundled with the CLI"
+	case SpecSourceImported:
+		origin = fmt.Sprintf("imported at %s", e.FetchedAt.Local().Format(time.DateTime))
+	default:
+		origin = fmt.Sprintf("fetched at %s", e.FetchedAt.Local().Format(time.DateTime))
+	}
+	if e.Version == "" {
+		return origin
+	}
+	return fmt.Sprintf("version %s, %s", e.Version, origin)
+}
+
+func newSpecCacheEntry(u string, data []byte, source string) *SpecCacheEntry {
+	return &SpecCacheEntry{
+		Service:   serviceFromURL(u),
+		URL:       u,
+		Source:    source,
+		Version:   SpecVersion(data),
+		FetchedAt: time.Now().UTC(),
+		Size:      len(data),
+		SHA256:    SpecHash(data),
+	}
+}
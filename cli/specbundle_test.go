@@ -0,0 +1,77 @@
+package cli_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/stretchr/testify/assert"
+)
+
+const bundledSpec = `{"openapi": "3.1.0", "info": {"version": "1.2.0"}, "paths": {}}`
+
+func TestReadSpecBundle(t *testing.T) {
+	bundle, err := cli.ReadSpecBundle(fstest.MapFS{
+		"vault.json":         {Data: []byte(bundledSpec)},
+		"specs/redact.json":  {Data: []byte(bundledSpec)},
+		"README.md":          {Data: []byte("# Specs")},
+		"specs/.hidden.yaml": {Data: []byte("openapi: 3.1.0")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"redact", "vault"}, bundle.Services())
+	assert.Equal(t, "1.2.0", cli.SpecVersion(bundle["vault"]))
+
+	_, err = cli.ReadSpecBundle(fstest.MapFS{"vault.json": {Data: []byte(`{"status": "Unauthorized"}`)}})
+	assert.ErrorContains(t, err, "vault.json is not an OpenAPI spec")
+
+	_, err = cli.ReadSpecBundle(fstest.MapFS{
+		"vault.json":     {Data: []byte(bundledSpec)},
+		"old/vault.json": {Data: []byte(bundledSpec)},
+	})
+	assert.ErrorContains(t, err, "vault spec is duplicated")
+}
+
+func TestReadSpecBundleTar(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "specs.tar.gz")
+	f, err := os.Create(name)
+	assert.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	assert.NoError(t, tw.WriteHeader(&tar.Header{Name: "specs/", Typeflag: tar.TypeDir, Mode: 0755}))
+	assert.NoError(t, tw.WriteHeader(&tar.Header{Name: "specs/embargo.json", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(bundledSpec))}))
+	_, err = tw.Write([]byte(bundledSpec))
+	assert.NoError(t, err)
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gz.Close())
+	assert.NoError(t, f.Close())
+
+	bundle, err := cli.ReadSpecBundleFile(name)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"embargo"}, bundle.Services())
+}
+
+func TestImportSpecOffline(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(cli.CacheTTLEnvVar, "0s")
+
+	// Nothing listens on it
+	u := "http://127.0.0.1:1/v1/openapi.json"
+	_, err := cli.LoadSpec(u)
+	assert.Error(t, err)
+
+	entry, err := cli.ImportSpec(u, []byte(bundledSpec))
+	assert.NoError(t, err)
+	assert.Equal(t, cli.SpecSourceImported, entry.Source)
+	assert.Equal(t, "1.2.0", entry.Version)
+	assert.Contains(t, entry.Origin(), "version 1.2.0, imported at")
+
+	// Expired but could not be revalidated, so the imported spec is used
+	b, err := cli.LoadSpec(u)
+	assert.NoError(t, err)
+	assert.Equal(t, bundledSpec, string(b))
+	assert.Equal(t, cli.SpecSourceImported, cli.CachedSpec(u).Source)
+}
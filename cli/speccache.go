@@ -0,0 +1,460 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variable with the time cached specs are used before revalidating them, like `12h`.
+const CacheTTLEnvVar = "PANGEA_CLI_CACHE_TTL"
+
+const DefaultCacheTTL = 24 * time.Hour
+
+// Cached specs not fetched nor revalidated for this long are removed
+const cacheMaxAge = 30 * 24 * time.Hour
+
+// Folders, inside the cache one, where specs and command indexes are saved
+const (
+	specsFolder    = "specs"
+	previousFolder = "previous"
+	indexFolder    = "index"
+)
+
+const metadataSuffix = ".meta.json"
+
+// SpecCacheEntry is the metadata of a cached spec, used to revalidate it with conditional requests.
+type SpecCacheEntry struct {
+	Service      string    `json:"service"`
+	URL          string    `json:"url"`
+	Source       string    `json:"source,omitempty"`
+	Version      string    `json:"version,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	Size         int       `json:"size"`
+	SHA256       string    `json:"sha256"`
+}
+
+// Expired returns if the entry should be revalidated before using it.
+func (e *SpecCacheEntry) Expired() bool {
+	return time.Since(e.FetchedAt) >= GetCacheTTL()
+}
+
+// GetCacheTTL returns the time cached specs are used before revalidating them.
+func GetCacheTTL() time.Duration {
+	env := os.Getenv(CacheTTLEnvVar)
+	if env == "" {
+		return DefaultCacheTTL
+	}
+
+	ttl, err := time.ParseDuration(env)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid %s value '%s'. Using %s.\n", CacheTTLEnvVar, env, DefaultCacheTTL)
+		return DefaultCacheTTL
+	}
+	return ttl
+}
+
+// GetCacheFilename returns the file where the spec on a URL is cached. Its metadata is saved next to it.
+func GetCacheFilename(u string) (string, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+
+	cacheDir, err := GetCacheFolder()
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.ReplaceAll(fmt.Sprintf("%s_%s", pu.Host, pu.Path), "/", "_")
+	return filepath.Join(cacheDir, specsFolder, name), nil
+}
+
+// GetIndexFilename returns the file where the command index of a spec is cached for this CLI version.
+func GetIndexFilename(specHash string) (string, error) {
+	cacheDir, err := GetCacheFolder()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, indexFolder, fmt.Sprintf("%s_%s.json", specHash, Version)), nil
+}
+
+// SpecHash returns the hash of a spec, used to check cached files and identify command indexes.
+func SpecHash(spec []byte) string {
+	h := sha256.Sum256(spec)
+	return hex.EncodeToString(h[:])
+}
+
+// LoadSpec returns the spec on the URL. Cached specs are used until they expire, then they're
+// revalidated with a conditional request and only downloaded again if they changed. If they could not
+// be revalidated, the cached ones are used, and if there are none, the ones bundled with the CLI.
+func LoadSpec(u string) ([]byte, error) {
+	// Cache is skipped with cassettes so specs are recorded and replayed too
+	switch GetCassetteMode() {
+	case CassetteReplay:
+		return download(u)
+	case CassetteRecord:
+		return fetchSpec(u, nil, nil)
+	}
+
+	entry, data := loadCacheEntry(u)
+	if entry != nil && !entry.Expired() {
+		return data, nil
+	}
+	return fetchSpec(u, entry, data)
+}
+
+// RefreshSpec revalidates the cached spec on the URL, or downloads it if it's not cached, regardless of
+// its TTL. It returns if the spec changed.
+func RefreshSpec(u string) (*SpecCacheEntry, bool, error) {
+	entry, data := loadCacheEntry(u)
+	hash := ""
+	if entry != nil {
+		hash = entry.SHA256
+	}
+
+	data, err := fetchSpec(u, entry, data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	entry, _ = loadCacheEntry(u)
+	if entry == nil {
+		return nil, false, fmt.Errorf("failed to cache spec from %s", u)
+	}
+	return entry, hash != SpecHash(data), nil
+}
+
+// CachedSpec returns the metadata of the cached spec on the URL, or nil if it's not cached.
+func CachedSpec(u string) *SpecCacheEntry {
+	entry, _ := loadCacheEntry(u)
+	return entry
+}
+
+// CachedSpecMetadata returns the metadata of the cached spec on the URL, without reading the spec, or
+// nil if it's not cached.
+func CachedSpecMetadata(u string) *SpecCacheEntry {
+	filename, err := GetCacheFilename(u)
+	if err != nil {
+		return nil
+	}
+	entry, err := readCacheMetadata(filename + metadataSuffix)
+	if err != nil {
+		return nil
+	}
+	return entry
+}
+
+// CachedSpecData returns the cached spec on the URL and its metadata, or an error if it's not cached.
+func CachedSpecData(u string) ([]byte, *SpecCacheEntry, error) {
+	entry, data := loadCacheEntry(u)
+	if entry == nil {
+		return nil, nil, fmt.Errorf("spec from %s is not cached", u)
+	}
+	return data, entry, nil
+}
+
+// PreviousSpecData returns the spec cached on the URL before the current one was fetched or imported,
+// and its metadata, or an error if there is none.
+func PreviousSpecData(u string) ([]byte, *SpecCacheEntry, error) {
+	filename, err := GetCacheFilename(u)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entry, data := loadCacheFile(previousFilename(filename))
+	if entry == nil {
+		return nil, nil, fmt.Errorf("there is no previous spec from %s on cache", u)
+	}
+	return data, entry, nil
+}
+
+// DownloadSpec downloads the spec on the URL without caching it.
+func DownloadSpec(u string) ([]byte, error) {
+	resp, err := HTTPClient().Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", u, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// ListCachedSpecs returns the metadata of the cached specs.
+func ListCachedSpecs() ([]SpecCacheEntry, error) {
+	cacheDir, err := GetCacheFolder()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(cacheDir, specsFolder, "*"+metadataSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []SpecCacheEntry{}
+	for _, f := range files {
+		entry, err := readCacheMetadata(f)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, nil
+}
+
+func RemoveCachedFileFromURL(url string) error {
+	filename, err := GetCacheFilename(url)
+	if err != nil {
+		return err
+	}
+	_ = os.Remove(filename + metadataSuffix)
+	return os.Remove(filename)
+}
+
+// previousFilename returns the file where the spec cached on filename is moved when it's replaced.
+func previousFilename(filename string) string {
+	return filepath.Join(filepath.Dir(filename), previousFolder, filepath.Base(filename))
+}
+
+// PruneCache removes the specs not fetched for a long time, except imported ones, the previous specs and
+// command indexes of specs no longer cached, the indexes of other CLI versions, and the day folders used
+// by previous CLI versions.
+func PruneCache() error {
+	cacheDir, err := GetCacheFolder()
+	if err != nil {
+		return err
+	}
+
+	dirs, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return err
+	}
+	for _, d := range dirs {
+		if _, err := strconv.Atoi(d.Name()); err == nil && d.IsDir() {
+			_ = os.RemoveAll(filepath.Join(cacheDir, d.Name()))
+		}
+	}
+
+	entries, err := ListCachedSpecs()
+	if err != nil {
+		return err
+	}
+	hashes := map[string]bool{}
+	for _, e := range entries {
+		if e.Source != SpecSourceImported && time.Since(e.FetchedAt) > cacheMaxAge {
+			_ = RemoveCachedFileFromURL(e.URL)
+			continue
+		}
+		hashes[e.SHA256] = true
+	}
+
+	previous, err := filepath.Glob(filepath.Join(cacheDir, specsFolder, previousFolder, "*"+metadataSuffix))
+	if err != nil {
+		return err
+	}
+	for _, f := range previous {
+		current := filepath.Join(cacheDir, specsFolder, filepath.Base(f))
+		if _, err := os.Stat(current); err != nil {
+			_ = os.Remove(strings.TrimSuffix(f, metadataSuffix))
+			_ = os.Remove(f)
+		}
+	}
+
+	indexes, err := filepath.Glob(filepath.Join(cacheDir, indexFolder, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, f := range indexes {
+		hash, version, _ := strings.Cut(strings.TrimSuffix(filepath.Base(f), ".json"), "_")
+		if !hashes[hash] || version != Version {
+			_ = os.Remove(f)
+		}
+	}
+	return nil
+}
+
+// loadCacheEntry returns the cached spec on the URL and its metadata, or nil if it's not cached or
+// the file does not match its metadata.
+func loadCacheEntry(u string) (*SpecCacheEntry, []byte) {
+	filename, err := GetCacheFilename(u)
+	if err != nil {
+		return nil, nil
+	}
+	return loadCacheFile(filename)
+}
+
+func loadCacheFile(filename string) (*SpecCacheEntry, []byte) {
+	entry, err := readCacheMetadata(filename + metadataSuffix)
+	if err != nil {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil || SpecHash(data) != entry.SHA256 {
+		return nil, nil
+	}
+	return entry, data
+}
+
+func readCacheMetadata(filename string) (*SpecCacheEntry, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry SpecCacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// fetchSpec requests the spec on the URL, conditionally if there is a cached entry. Successful
+// responses are saved to cache. Other responses are returned but not cached. If the request fails, the
+// cached spec is used, or the bundled one if there is no cached spec or it was bundled with another CLI
+// version.
+func fetchSpec(u string, entry *SpecCacheEntry, cached []byte) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "Downloading from %s...\n", u)
+	}
+
+	resp, err := HTTPClient().Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		if err == nil {
+			err = fmt.Errorf("failed to download %s: %s", u, resp.Status)
+		}
+		return fetchFallback(u, entry, cached, err)
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		entry.FetchedAt = time.Now().UTC()
+		_ = saveCacheEntry(u, entry, nil)
+		return cached, nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return data, nil
+	}
+
+	fetched := newSpecCacheEntry(u, data, SpecSourceNetwork)
+	fetched.ETag = resp.Header.Get("ETag")
+	fetched.LastModified = resp.Header.Get("Last-Modified")
+	err = saveCacheEntry(u, fetched, data)
+	if entry == nil {
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "Downloaded. Saved to cache.\n")
+		} else {
+			fmt.Fprintf(os.Stderr, "Downloaded but failed to save to cache.\n")
+		}
+	}
+	if err == nil {
+		_ = PruneCache()
+	}
+	return data, nil
+}
+
+// fetchFallback returns the spec to use when the one on the URL could not be fetched.
+func fetchFallback(u string, entry *SpecCacheEntry, cached []byte, fetchErr error) ([]byte, error) {
+	bundled := BundledSpec(serviceFromURL(u))
+	if entry != nil && (entry.Source != SpecSourceBundled || bundled == nil || entry.SHA256 == SpecHash(bundled)) {
+		fmt.Fprintf(os.Stderr, "Failed to revalidate cached spec from %s. Using cached one, %s.\n", u, entry.Origin())
+		return cached, nil
+	}
+	if bundled == nil {
+		return nil, fetchErr
+	}
+
+	entry = newSpecCacheEntry(u, bundled, SpecSourceBundled)
+	fmt.Fprintf(os.Stderr, "Failed to download spec from %s. Using the one bundled with the CLI.\n", u)
+	_ = saveCacheEntry(u, entry, bundled)
+	return bundled, nil
+}
+
+// saveCacheEntry saves the metadata of a spec, and the spec itself if it's not nil. A different cached
+// spec is kept as the previous one.
+func saveCacheEntry(u string, entry *SpecCacheEntry, data []byte) error {
+	filename, err := GetCacheFilename(u)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+
+	if current, _ := loadCacheFile(filename); data != nil && current != nil && current.SHA256 != entry.SHA256 {
+		previous := previousFilename(filename)
+		if err := os.MkdirAll(filepath.Dir(previous), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(filename, previous); err != nil {
+			return err
+		}
+		if err := os.Rename(filename+metadataSuffix, previous+metadataSuffix); err != nil {
+			return err
+		}
+	}
+
+	if data != nil {
+		if err := os.WriteFile(filename, data, 0644); err != nil {
+			return err
+		}
+	}
+
+	b, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename+metadataSuffix, b, 0644)
+}
+
+func download(u string) ([]byte, error) {
+	resp, err := HTTPClient().Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+// serviceFromURL returns the service of a Pangea URL, the first label of its host.
+func serviceFromURL(u string) string {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	svc, _, _ := strings.Cut(pu.Hostname(), ".")
+	return svc
+}
@@ -0,0 +1,126 @@
+package cli_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSpecRevalidates(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	spec := `{"openapi": "3.0.0"}`
+	etag := `"v1"`
+	downloads, notModified := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(spec))
+	}))
+	defer ts.Close()
+	u := ts.URL + "/v1/openapi.json"
+
+	b, err := cli.LoadSpec(u)
+	assert.NoError(t, err)
+	assert.Equal(t, spec, string(b))
+
+	// Not expired, so the server is not requested
+	_, err = cli.LoadSpec(u)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, downloads)
+	assert.Equal(t, 0, notModified)
+
+	t.Setenv(cli.CacheTTLEnvVar, "0s")
+	b, err = cli.LoadSpec(u)
+	assert.NoError(t, err)
+	assert.Equal(t, spec, string(b))
+	assert.Equal(t, 1, downloads)
+	assert.Equal(t, 1, notModified)
+
+	// Changed specs are downloaded again
+	spec, etag = `{"openapi": "3.1.0"}`, `"v2"`
+	entry, changed, err := cli.RefreshSpec(u)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, etag, entry.ETag)
+	assert.Equal(t, len(spec), entry.Size)
+
+	// Replaced spec is kept as the previous one
+	prev, prevEntry, err := cli.PreviousSpecData(u)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"openapi": "3.0.0"}`, string(prev))
+	assert.Equal(t, `"v1"`, prevEntry.ETag)
+
+	entries, err := cli.ListCachedSpecs()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, u, entries[0].URL)
+	assert.Equal(t, cli.SpecHash([]byte(spec)), entries[0].SHA256)
+}
+
+func TestLoadSpecServerError(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	fail := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	u := ts.URL + "/v1/openapi.json"
+
+	_, err := cli.LoadSpec(u)
+	assert.NoError(t, err)
+
+	// Cached spec is used if it could not be revalidated
+	fail = true
+	t.Setenv(cli.CacheTTLEnvVar, "0s")
+	b, err := cli.LoadSpec(u)
+	assert.NoError(t, err)
+	assert.Equal(t, `{}`, string(b))
+
+	_, err = cli.LoadSpec(strings.Replace(u, "/v1/", "/v2/", 1))
+	assert.Error(t, err)
+}
+
+func TestPruneCache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cacheDir := filepath.Join(home, ".pangea", "cache")
+
+	legacy := filepath.Join(cacheDir, "19000")
+	assert.NoError(t, os.MkdirAll(legacy, 0755))
+	index, err := cli.GetIndexFilename("unknownhash")
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(index), 0755))
+	assert.NoError(t, os.WriteFile(index, []byte(`{}`), 0644))
+	releases := filepath.Join(cacheDir, "cache.json")
+	assert.NoError(t, os.WriteFile(releases, []byte(`{}`), 0644))
+
+	assert.NoError(t, cli.PruneCache())
+	assert.NoDirExists(t, legacy)
+	assert.NoFileExists(t, index)
+	assert.FileExists(t, releases)
+}
+
+func TestSpecCacheEntryExpired(t *testing.T) {
+	t.Setenv(cli.CacheTTLEnvVar, "1h")
+	assert.False(t, (&cli.SpecCacheEntry{FetchedAt: time.Now().Add(-30 * time.Minute)}).Expired())
+	assert.True(t, (&cli.SpecCacheEntry{FetchedAt: time.Now().Add(-2 * time.Hour)}).Expired())
+}
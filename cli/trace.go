@@ -0,0 +1,314 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variable that enables HTTP tracing. Set it to `trace` to print headers too.
+const DebugEnvVar = "PANGEA_CLI_DEBUG"
+
+type TraceLevel int
+
+const (
+	TraceOff TraceLevel = iota
+	// TraceVerbose prints a line per HTTP request with its status, duration and request ID
+	TraceVerbose
+	// TraceHeaders also prints request and response headers
+	TraceHeaders
+)
+
+// Path of the Pangea endpoint used to poll queued requests results
+const pollPath = "/request/"
+
+// TraceTransport is an http.RoundTripper that logs every request to stderr and optionally records
+// them to be saved on a HAR like file. Authorization headers are always masked. Bodies are not
+// recorded since they could hold secrets.
+type TraceTransport struct {
+	Base http.RoundTripper
+
+	mu        sync.Mutex
+	level     TraceLevel
+	out       io.Writer
+	traceFile string
+	entries   []traceEntry
+	polls     map[string]int
+}
+
+// DefaultTransport is the transport used by all the Pangea clients created by the CLI.
+var DefaultTransport = NewTraceTransport(http.DefaultTransport, traceLevelFromEnv(), os.Stderr)
+
+// NewTraceTransport returns a TraceTransport that sends requests with base and logs them to out.
+func NewTraceTransport(base http.RoundTripper, level TraceLevel, out io.Writer) *TraceTransport {
+	return &TraceTransport{
+		Base:  base,
+		level: level,
+		out:   out,
+		polls: map[string]int{},
+	}
+}
+
+// HTTPClient returns an http.Client that uses DefaultTransport.
+func HTTPClient() *http.Client {
+	return &http.Client{Transport: DefaultTransport}
+}
+
+// SetupTrace configures DefaultTransport. level is only applied if it's higher than the one set by
+// PANGEA_CLI_DEBUG. If traceFile is not empty, requests are recorded to be saved by FlushTrace.
+func SetupTrace(level TraceLevel, traceFile string) {
+	t := DefaultTransport
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if level > t.level {
+		t.level = level
+	}
+	t.traceFile = traceFile
+}
+
+// FlushTrace writes the recorded requests to the trace file, if one was set.
+func FlushTrace() error {
+	t := DefaultTransport
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.traceFile == "" {
+		return nil
+	}
+
+	entries := t.entries
+	if entries == nil {
+		entries = []traceEntry{}
+	}
+	har := map[string]any{
+		"log": map[string]any{
+			"version": "1.2",
+			"creator": map[string]string{"name": "pangea-cli", "version": Version},
+			"entries": entries,
+		},
+	}
+
+	b, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.traceFile, b, 0600)
+}
+
+// MaskAuthorization hides the token of an authorization header, keeping only a few characters to identify it.
+func MaskAuthorization(v string) string {
+	scheme, token, found := strings.Cut(v, " ")
+	if !found {
+		token = scheme
+		scheme = ""
+	}
+
+	masked := "****"
+	if len(token) > 12 {
+		masked = token[:8] + masked
+	}
+	return strings.TrimSpace(scheme + " " + masked)
+}
+
+func (t *TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	level, record := t.level, t.traceFile != ""
+	t.mu.Unlock()
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if level == TraceOff && !record {
+		return base.RoundTrip(req)
+	}
+
+	poll := t.pollAttempt(req)
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	duration := time.Since(start)
+
+	var info responseInfo
+	if err == nil {
+		info = readResponseInfo(resp)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.level > TraceOff {
+		t.print(req, resp, err, duration, poll, info)
+	}
+	if t.traceFile != "" {
+		t.entries = append(t.entries, newTraceEntry(req, resp, err, start, duration, poll, info))
+	}
+	return resp, err
+}
+
+// pollAttempt returns the number of times the result of a queued request has been polled, including
+// this one, or 0 if req is not a poll request.
+func (t *TraceTransport) pollAttempt(req *http.Request) int {
+	_, id, found := strings.Cut(req.URL.Path, pollPath)
+	if req.Method != http.MethodGet || !found || id == "" {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.polls[id]++
+	return t.polls[id]
+}
+
+func (t *TraceTransport) print(req *http.Request, resp *http.Response, err error, duration time.Duration, poll int, info responseInfo) {
+	line := fmt.Sprintf("%s %s", req.Method, req.URL)
+	if err != nil {
+		line += fmt.Sprintf(" -> error: %v", err)
+	} else {
+		line += fmt.Sprintf(" -> %d", resp.StatusCode)
+	}
+	line += fmt.Sprintf(" (%s)", duration.Round(time.Millisecond))
+	if info.RequestID != "" {
+		line += " request_id=" + info.RequestID
+	}
+	if info.Status != "" {
+		line += " status=" + info.Status
+	}
+	if poll > 0 {
+		line += fmt.Sprintf(" poll_attempt=%d", poll)
+	}
+	fmt.Fprintln(t.out, line)
+
+	if t.level < TraceHeaders {
+		return
+	}
+	for _, h := range traceHeaders(req.Header) {
+		fmt.Fprintf(t.out, "  > %s: %s\n", h.Name, h.Value)
+	}
+	if resp != nil {
+		for _, h := range traceHeaders(resp.Header) {
+			fmt.Fprintf(t.out, "  < %s: %s\n", h.Name, h.Value)
+		}
+	}
+}
+
+type responseInfo struct {
+	RequestID string `json:"request_id"`
+	Status    string `json:"status"`
+	size      int
+}
+
+// readResponseInfo reads the request ID and status from a Pangea response. The body is restored so
+// it could be read again by the client.
+func readResponseInfo(resp *http.Response) responseInfo {
+	info := responseInfo{}
+	if resp.Body == nil || !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return info
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return info
+	}
+
+	_ = json.Unmarshal(b, &info)
+	info.size = len(b)
+	return info
+}
+
+type traceHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type traceEntry struct {
+	StartedDateTime string        `json:"startedDateTime"`
+	Time            float64       `json:"time"`
+	Request         traceRequest  `json:"request"`
+	Response        traceResponse `json:"response"`
+	RequestID       string        `json:"_request_id,omitempty"`
+	Status          string        `json:"_status,omitempty"`
+	PollAttempt     int           `json:"_poll_attempt,omitempty"`
+	Error           string        `json:"_error,omitempty"`
+}
+
+type traceRequest struct {
+	Method   string        `json:"method"`
+	URL      string        `json:"url"`
+	Headers  []traceHeader `json:"headers"`
+	BodySize int64         `json:"bodySize"`
+}
+
+type traceResponse struct {
+	Status     int           `json:"status"`
+	StatusText string        `json:"statusText"`
+	Headers    []traceHeader `json:"headers"`
+	BodySize   int           `json:"bodySize"`
+}
+
+func newTraceEntry(req *http.Request, resp *http.Response, err error, start time.Time, duration time.Duration, poll int, info responseInfo) traceEntry {
+	entry := traceEntry{
+		StartedDateTime: start.Format(time.RFC3339Nano),
+		Time:            float64(duration.Microseconds()) / 1000,
+		Request: traceRequest{
+			Method:   req.Method,
+			URL:      req.URL.String(),
+			Headers:  traceHeaders(req.Header),
+			BodySize: req.ContentLength,
+		},
+		RequestID:   info.RequestID,
+		Status:      info.Status,
+		PollAttempt: poll,
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	entry.Response = traceResponse{
+		Status:     resp.StatusCode,
+		StatusText: http.StatusText(resp.StatusCode),
+		Headers:    traceHeaders(resp.Header),
+		BodySize:   info.size,
+	}
+	return entry
+}
+
+func traceHeaders(h http.Header) []traceHeader {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := []traceHeader{}
+	for _, name := range names {
+		for _, v := range h.Values(name) {
+			if name == "Authorization" {
+				v = MaskAuthorization(v)
+			}
+			headers = append(headers, traceHeader{Name: name, Value: v})
+		}
+	}
+	return headers
+}
+
+func traceLevelFromEnv() TraceLevel {
+	switch strings.ToLower(os.Getenv(DebugEnvVar)) {
+	case "", "0", "false":
+		return TraceOff
+	case "trace":
+		return TraceHeaders
+	default:
+		return TraceVerbose
+	}
+}
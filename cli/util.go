@@ -104,6 +104,16 @@ func ReadStdin() string {
 // Logger that omits timestamp and still allows us to log fatal
 var logger = log.New(os.Stderr, "", 0)
 
+// ExitError ends the CLI with an exit code, without printing any error. Commands that wrap other
+// processes return it to exit with their code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
 func GetLogger() *log.Logger {
 	return logger
 }
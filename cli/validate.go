@@ -0,0 +1,353 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidationError describes a field of a request body that does not match its schema.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// formatCheckers validate string values with a `format`. Unknown formats are not checked.
+var formatCheckers = map[string]func(string) bool{
+	"date-time": func(s string) bool {
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	},
+	"date": func(s string) bool {
+		_, err := time.Parse(time.DateOnly, s)
+		return err == nil
+	},
+	"ipv4": func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && strings.Contains(s, ".")
+	},
+	"ipv6": func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && strings.Contains(s, ":")
+	},
+	"uuid": uuidRegexp.MatchString,
+	"email": func(s string) bool {
+		_, err := mail.ParseAddress(s)
+		return err == nil
+	},
+	"uri": func(s string) bool {
+		u, err := url.ParseRequestURI(s)
+		return err == nil && u.Scheme != ""
+	},
+}
+
+// AllProperties returns the properties of the schema merged with the ones of all its oneOf and anyOf
+// variants.
+func (s *Schema) AllProperties() Properties {
+	props := Properties{}
+	if s == nil {
+		return props
+	}
+
+	for name, prop := range s.Properties {
+		props[name] = prop
+	}
+	for _, sch := range s.OneOf {
+		for name, prop := range sch.AllProperties() {
+			if _, ok := props[name]; !ok {
+				props[name] = prop
+			}
+		}
+	}
+	for _, sch := range s.AnyOf {
+		for name, prop := range sch.AllProperties() {
+			if _, ok := props[name]; !ok {
+				props[name] = prop
+			}
+		}
+	}
+	return props
+}
+
+// Validate checks a request body against the schema and returns all the violations found, sorted by field.
+// Nested fields are reported with dotted names, the same ones used by the nested flags.
+//
+// If the schema has oneOf or anyOf variants, body is valid if it matches any of them. Otherwise the
+// violations of the closest variant are returned.
+func (s *Schema) Validate(body map[string]any) []ValidationError {
+	if s == nil {
+		return []ValidationError{}
+	}
+
+	// Compare against the generic JSON types, the same ones that will be sent
+	if b, err := json.Marshal(body); err == nil {
+		var normalized map[string]any
+		if json.Unmarshal(b, &normalized) == nil {
+			body = normalized
+		}
+	}
+
+	var errs []ValidationError
+	branches := append(append([]Schema{}, s.OneOf...), s.AnyOf...)
+	if len(branches) == 0 {
+		errs = validateObject("", s.Properties, s.Required, s.AdditionalProperties, body)
+	} else {
+		for _, branch := range branches {
+			berrs := validateBranch(s, &branch, body)
+			if errs == nil || len(berrs) < len(errs) {
+				errs = berrs
+			}
+			if len(errs) == 0 {
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Field < errs[j].Field
+	})
+	return errs
+}
+
+// validateBranch validates body against a variant merged with the properties shared by all variants.
+func validateBranch(base, branch *Schema, body map[string]any) []ValidationError {
+	merged := &Schema{
+		Properties:           Properties{},
+		OneOf:                branch.OneOf,
+		AnyOf:                branch.AnyOf,
+		AdditionalProperties: branch.AdditionalProperties,
+	}
+	if merged.AdditionalProperties == nil {
+		merged.AdditionalProperties = base.AdditionalProperties
+	}
+	for _, src := range []*Schema{base, branch} {
+		for name, prop := range src.Properties {
+			merged.Properties[name] = prop
+		}
+		merged.Required = append(merged.Required, src.Required...)
+	}
+	return merged.Validate(body)
+}
+
+// validateObject validates the fields of an object. Fields that are not on its properties are checked
+// against additional, and reported as unknown only if they're not allowed.
+func validateObject(parent string, props Properties, required []string, additional *AdditionalProperties, obj map[string]any) []ValidationError {
+	errs := []ValidationError{}
+
+	seen := map[string]bool{}
+	for _, name := range required {
+		if _, ok := obj[name]; !ok && !seen[name] {
+			errs = append(errs, ValidationError{Field: fieldName(parent, name), Message: "required field is missing"})
+		}
+		seen[name] = true
+	}
+
+	for name, value := range obj {
+		prop, ok := props[name]
+		switch {
+		case ok:
+			errs = append(errs, validateValue(fieldName(parent, name), prop, value)...)
+		case !additional.AllowsField():
+			errs = append(errs, ValidationError{Field: fieldName(parent, name), Message: "unknown field"})
+		case additional != nil && additional.Schema != nil:
+			errs = append(errs, validateValue(fieldName(parent, name), *additional.Schema, value)...)
+		}
+	}
+	return errs
+}
+
+func validateValue(field string, prop Property, value any) []ValidationError {
+	invalid := func(format string, args ...any) []ValidationError {
+		return []ValidationError{{Field: field, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	types := prop.Types()
+	if len(types) > 0 && !matchesAnyType(types, value) {
+		return invalid("invalid type %s. Expected %s", jsonType(value), strings.Join(types, " or "))
+	}
+	if value == nil && prop.HasType("null") {
+		return nil
+	}
+
+	// Value should match any of the variants. Otherwise the violations of the closest one are returned.
+	if variants := append(slices.Clone(prop.OneOf), prop.AnyOf...); len(variants) > 0 {
+		var errs []ValidationError
+		for _, v := range variants {
+			verrs := validateValue(field, v, value)
+			if errs == nil || len(verrs) < len(errs) {
+				errs = verrs
+			}
+			if len(errs) == 0 {
+				return nil
+			}
+		}
+		return errs
+	}
+
+	if prop.Const != nil && !reflect.DeepEqual(normalizeValue(prop.Const), value) {
+		return invalid("invalid value %s. Expected %s", valueString(value), valueString(prop.Const))
+	}
+
+	if len(prop.Enum) > 0 && !inEnum(prop.Enum, value) {
+		vals := make([]string, 0, len(prop.Enum))
+		for _, e := range prop.Enum {
+			vals = append(vals, valueString(e))
+		}
+		return invalid("invalid value %s. Possible values: [%s]", valueString(value), strings.Join(vals, " "))
+	}
+
+	switch v := value.(type) {
+	case string:
+		n := utf8.RuneCountInString(v)
+		if prop.MinLength != nil && n < *prop.MinLength {
+			return invalid("should have at least %d characters", *prop.MinLength)
+		}
+		if prop.MaxLength != nil && n > *prop.MaxLength {
+			return invalid("should have at most %d characters", *prop.MaxLength)
+		}
+		if check, ok := formatCheckers[prop.Format]; ok && !check(v) {
+			return invalid("invalid %s value '%s'", prop.Format, v)
+		}
+		// Patterns not supported by Go regular expressions are not checked
+		if re, err := regexp.Compile(prop.Pattern); prop.Pattern != "" && err == nil && !re.MatchString(v) {
+			return invalid("should match pattern '%s'", prop.Pattern)
+		}
+	case float64:
+		if prop.Minimum != nil && v < *prop.Minimum {
+			return invalid("should be at least %s", valueString(*prop.Minimum))
+		}
+		if prop.Maximum != nil && v > *prop.Maximum {
+			return invalid("should be at most %s", valueString(*prop.Maximum))
+		}
+	case []any:
+		if prop.MinItems != nil && len(v) < *prop.MinItems {
+			return invalid("should have at least %d items", *prop.MinItems)
+		}
+		if prop.MaxItems != nil && len(v) > *prop.MaxItems {
+			return invalid("should have at most %d items", *prop.MaxItems)
+		}
+		if prop.Items != nil {
+			errs := []ValidationError{}
+			for i, item := range v {
+				errs = append(errs, validateValue(fmt.Sprintf("%s[%d]", field, i), *prop.Items, item)...)
+			}
+			return errs
+		}
+	case map[string]any:
+		if len(prop.Properties) > 0 || prop.AdditionalProperties != nil {
+			return validateObject(field, prop.Properties, prop.Required, prop.AdditionalProperties, v)
+		}
+	}
+	return nil
+}
+
+func matchesAnyType(types []string, value any) bool {
+	for _, t := range types {
+		if matchesType(t, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesType(t string, value any) bool {
+	switch t {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "integer":
+		v, ok := value.(float64)
+		return ok && v == math.Trunc(v)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "null":
+		return value == nil
+	default:
+		// Unknown types are not checked
+		return true
+	}
+}
+
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(normalizeValue(e), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeValue converts schema values to the generic JSON types, so they could be compared with the body ones.
+func normalizeValue(v any) any {
+	n, err := normalize(v)
+	if err != nil {
+		return v
+	}
+	return n
+}
+
+func valueString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func fieldName(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,279 @@
+package cli_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaValidateNested(t *testing.T) {
+	closed := &cli.AdditionalProperties{Allowed: false}
+	schema := cli.Schema{
+		Required: []string{"event"},
+		Properties: cli.Properties{
+			"event": cli.Property{
+				Type:     cli.SchemaType{"object"},
+				Required: []string{"message"},
+				Properties: cli.Properties{
+					"message": cli.Property{Type: cli.SchemaType{"string"}},
+					"actor":   cli.Property{Type: cli.SchemaType{"string"}},
+				},
+				AdditionalProperties: closed,
+			},
+		},
+		AdditionalProperties: closed,
+	}
+
+	errs := schema.Validate(map[string]any{"event": map[string]any{"message": "hello", "actor": "me"}})
+	assert.Empty(t, errs)
+
+	errs = schema.Validate(map[string]any{"event": map[string]any{"actr": "me"}, "other": 1})
+	assert.Equal(t, []cli.ValidationError{
+		{Field: "event.actr", Message: "unknown field"},
+		{Field: "event.message", Message: "required field is missing"},
+		{Field: "other", Message: "unknown field"},
+	}, errs)
+
+	errs = schema.Validate(map[string]any{})
+	assert.Equal(t, []cli.ValidationError{{Field: "event", Message: "required field is missing"}}, errs)
+}
+
+func TestSchemaValidateAdditionalProperties(t *testing.T) {
+	var schema cli.Schema
+	err := json.Unmarshal([]byte(`{
+		"properties": {
+			"name": {"type": "string"},
+			"labels": {"type": "object", "additionalProperties": {"type": "string", "maxLength": 3}},
+			"extra": {"type": "object", "properties": {"id": {"type": "string"}}, "additionalProperties": true}
+		}
+	}`), &schema)
+	assert.NoError(t, err)
+	assert.Nil(t, schema.AdditionalProperties)
+	assert.Equal(t, cli.SchemaType{"string"}, schema.Properties["labels"].AdditionalProperties.Schema.Type)
+
+	// Fields are allowed unless additionalProperties is false
+	errs := schema.Validate(map[string]any{
+		"name":   "n",
+		"other":  1,
+		"labels": map[string]any{"env": "dev", "team": "security", "n": 1},
+		"extra":  map[string]any{"id": 1, "more": true},
+	})
+	assert.Equal(t, []cli.ValidationError{
+		{Field: "extra.id", Message: "invalid type integer. Expected string"},
+		{Field: "labels.n", Message: "invalid type integer. Expected string"},
+		{Field: "labels.team", Message: "should have at most 3 characters"},
+	}, errs)
+
+	b, err := json.Marshal(schema.Properties["labels"])
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type": "object", "additionalProperties": {"type": "string", "maxLength": 3}}`, string(b))
+}
+
+func TestSchemaValidateValues(t *testing.T) {
+	minLength := 3
+	schema := cli.Schema{
+		Properties: cli.Properties{
+			"id":      cli.Property{Type: cli.SchemaType{"string"}, Format: "uuid"},
+			"ip":      cli.Property{Type: cli.SchemaType{"string"}, Format: "ipv4"},
+			"start":   cli.Property{Type: cli.SchemaType{"string"}, Format: "date-time"},
+			"name":    cli.Property{Type: cli.SchemaType{"string", "null"}, MinLength: &minLength},
+			"limit":   cli.Property{Type: cli.SchemaType{"integer"}},
+			"verbose": cli.Property{Type: cli.SchemaType{"boolean"}},
+			"order":   cli.Property{Type: cli.SchemaType{"string"}, Enum: []any{"asc", "desc"}},
+		},
+	}
+
+	limit := 10
+	errs := schema.Validate(map[string]any{
+		"id":      "0c3f0a6c-8b0e-4a5b-9c1d-2e3f4a5b6c7d",
+		"ip":      "190.28.74.251",
+		"start":   "2024-10-16T10:00:00Z",
+		"name":    nil,
+		"limit":   &limit,
+		"verbose": true,
+		"order":   "asc",
+	})
+	assert.Empty(t, errs)
+
+	errs = schema.Validate(map[string]any{
+		"id":      "pvi_123",
+		"ip":      "::1",
+		"start":   "yesterday",
+		"name":    "ab",
+		"limit":   1.5,
+		"verbose": "yes",
+		"order":   "random",
+	})
+	assert.Equal(t, []cli.ValidationError{
+		{Field: "id", Message: "invalid uuid value 'pvi_123'"},
+		{Field: "ip", Message: "invalid ipv4 value '::1'"},
+		{Field: "limit", Message: "invalid type number. Expected integer"},
+		{Field: "name", Message: "should have at least 3 characters"},
+		{Field: "order", Message: "invalid value random. Possible values: [asc desc]"},
+		{Field: "start", Message: "invalid date-time value 'yesterday'"},
+		{Field: "verbose", Message: "invalid type string. Expected boolean"},
+	}, errs)
+}
+
+func TestSchemaValidateItemsAndLimits(t *testing.T) {
+	minimum, maximum := 1.0, 100.0
+	schema := cli.Schema{
+		Properties: cli.Properties{
+			"ports": cli.Property{
+				Type:  cli.SchemaType{"array"},
+				Items: &cli.Property{Type: cli.SchemaType{"integer"}, Minimum: &minimum, Maximum: &maximum},
+			},
+			"code":   cli.Property{Type: cli.SchemaType{"string"}, Pattern: "^[A-Z]{2}$"},
+			"parent": cli.Property{Type: cli.SchemaType{"string"}, Nullable: true, Enum: []any{"root"}},
+			"id": cli.Property{AnyOf: []cli.Property{
+				{Type: cli.SchemaType{"string"}, Format: "uuid"},
+				{Type: cli.SchemaType{"integer"}},
+			}},
+		},
+	}
+
+	errs := schema.Validate(map[string]any{"ports": []int{1, 80}, "code": "US", "parent": nil, "id": 12})
+	assert.Empty(t, errs)
+
+	errs = schema.Validate(map[string]any{"ports": []any{0, "80", 101}, "code": "usa", "id": "pvi_123"})
+	assert.Equal(t, []cli.ValidationError{
+		{Field: "code", Message: "should match pattern '^[A-Z]{2}$'"},
+		{Field: "id", Message: "invalid uuid value 'pvi_123'"},
+		{Field: "ports[0]", Message: "should be at least 1"},
+		{Field: "ports[1]", Message: "invalid type string. Expected integer"},
+		{Field: "ports[2]", Message: "should be at most 100"},
+	}, errs)
+}
+
+const allOfSpec = `{
+	"paths": {
+		"/v1/create": {"post": {"requestBody": {"content": {"application/json": {"schema": {
+			"allOf": [{"$ref": "#/components/schemas/Base"}, {"properties": {"name": {"type": "string"}}, "required": ["name"]}]
+		}}}}}}
+	},
+	"components": {"schemas": {
+		"Base": {"properties": {
+			"id": {"type": "string"},
+			"tags": {"type": "array", "items": {"$ref": "#/components/schemas/Tag"}},
+			"owner": {"anyOf": [{"$ref": "#/components/schemas/Owner"}, {"type": "null"}], "description": "Item owner."},
+			"size": {"type": ["integer", "string"]}
+		}, "required": ["id"]},
+		"Tag": {"type": "object", "properties": {"key": {"type": "string"}}},
+		"Owner": {"type": "object", "description": "An owner.", "properties": {"email": {"type": "string", "format": "email"}}}
+	}}
+}`
+
+func TestLoadAllOfAndRefs(t *testing.T) {
+	oapi, err := cli.LoadReader(strings.NewReader(allOfSpec), "")
+	assert.NoError(t, err)
+
+	schema := oapi.Paths["/v1/create"].Post.RequestBody.Content[cli.ApplicationJSON].Schema
+	assert.ElementsMatch(t, []string{"name", "id"}, schema.Required)
+	assert.ElementsMatch(t, []string{"id", "name", "tags", "owner", "size"}, slices.Collect(maps.Keys(schema.Properties)))
+
+	tags := schema.Properties["tags"]
+	assert.Equal(t, cli.SchemaType{"object"}, tags.Items.Type)
+	assert.Contains(t, tags.Items.Properties, "key")
+
+	owner := schema.Properties["owner"]
+	assert.Equal(t, []string{"object", "null"}, owner.Types())
+	assert.Equal(t, "Item owner.", owner.Description)
+	assert.Contains(t, owner.Properties, "email")
+
+	assert.Equal(t, []string{"integer", "string"}, schema.Properties["size"].NonNullTypes())
+
+	errs := schema.Validate(map[string]any{"id": "1", "name": "n", "owner": map[string]any{"email": "me"}})
+	assert.Equal(t, []cli.ValidationError{{Field: "owner.email", Message: "invalid email value 'me'"}}, errs)
+}
+
+func TestLoadInvalidRef(t *testing.T) {
+	spec := strings.Replace(allOfSpec, "#/components/schemas/Tag", "#/components/schemas/Missing", 1)
+	_, err := cli.LoadReader(strings.NewReader(spec), "")
+	assert.ErrorContains(t, err, "schema 'Missing' not found")
+	assert.ErrorContains(t, err, "/v1/create")
+}
+
+const recursiveSpec = `{
+	"paths": {
+		"/v1/key": {"post": {"requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Key"}}}}}},
+		"/v1/shape": {"post": {"requestBody": {"content": {"application/json": {"schema": {"oneOf": [{"$ref": "#/components/schemas/Circle"}]}}}}}},
+		"/v1/tree": {"post": {"requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Tree"}}}}}}
+	},
+	"components": {"schemas": {
+		"Key": {
+			"required": ["type"],
+			"properties": {"type": {"type": "string"}, "name": {"type": "string"}},
+			"discriminator": {"propertyName": "type", "mapping": {"symmetric_key": "#/components/schemas/SymmetricKey"}}
+		},
+		"SymmetricKey": {"allOf": [{"$ref": "#/components/schemas/Key"}, {"properties": {"algorithm": {"type": "string"}}}]},
+		"Circle": {"allOf": [{"$ref": "#/components/schemas/Shape"}, {"properties": {"radius": {"type": "number"}}}]},
+		"Shape": {"properties": {"color": {"type": "string"}}, "oneOf": [{"$ref": "#/components/schemas/Circle"}]},
+		"Tree": {"properties": {
+			"root": {"$ref": "#/components/schemas/Node"},
+			"config": {"$ref": "#/components/schemas/Level1"},
+			"labels": {"type": "object", "additionalProperties": {"$ref": "#/components/schemas/Level4"}}
+		}},
+		"Node": {"type": "object", "properties": {"name": {"type": "string"}, "children": {"type": "array", "items": {"$ref": "#/components/schemas/Node"}}}},
+		"Level1": {"type": "object", "properties": {"level2": {"$ref": "#/components/schemas/Level2"}}},
+		"Level2": {"allOf": [{"$ref": "#/components/schemas/Level3"}]},
+		"Level3": {"type": "object", "properties": {"level4": {"$ref": "#/components/schemas/Level4"}}},
+		"Level4": {"type": "object", "properties": {"size": {"type": "integer"}}}
+	}}
+}`
+
+func TestLoadRecursiveRefs(t *testing.T) {
+	oapi, err := cli.LoadReader(strings.NewReader(recursiveSpec), "")
+	assert.NoError(t, err)
+
+	key := oapi.Paths["/v1/key"].Post.RequestBody.Content[cli.ApplicationJSON].Schema
+	variant, err := key.Variant("symmetric_key")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"type", "name", "algorithm"}, slices.Collect(maps.Keys(variant.Properties)))
+	assert.Equal(t, []string{"type"}, variant.Required)
+
+	shape := oapi.Paths["/v1/shape"].Post.RequestBody.Content[cli.ApplicationJSON].Schema
+	assert.Len(t, shape.OneOf, 1)
+	assert.ElementsMatch(t, []string{"color", "radius"}, slices.Collect(maps.Keys(shape.OneOf[0].Properties)))
+
+	tree := oapi.Paths["/v1/tree"].Post.RequestBody.Content[cli.ApplicationJSON].Schema
+	children := tree.Properties["root"].Properties["children"]
+	assert.Equal(t, cli.SchemaType{"object"}, children.Items.Type)
+	assert.Empty(t, children.Items.Ref)
+	assert.Empty(t, children.Items.Properties)
+
+	size := tree.Properties["config"].Properties["level2"].Properties["level4"].Properties["size"]
+	assert.Equal(t, cli.SchemaType{"integer"}, size.Type)
+
+	labels := tree.Properties["labels"].AdditionalProperties.Schema
+	assert.Empty(t, labels.Ref)
+	assert.Contains(t, labels.Properties, "size")
+}
+
+func TestLoadDeepRef(t *testing.T) {
+	schemas := []string{}
+	for i := range 12 {
+		schemas = append(schemas, fmt.Sprintf(`"L%d": {"type": "object", "properties": {"next": {"$ref": "#/components/schemas/L%d"}}}`, i, i+1))
+	}
+	schemas = append(schemas, `"L12": {"type": "string"}`)
+	spec := `{"paths": {"/v1/deep": {"post": {"requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/L0"}}}}}}},
+		"components": {"schemas": {` + strings.Join(schemas, ",") + `}}}`
+
+	_, err := cli.LoadReader(strings.NewReader(spec), "")
+	assert.ErrorContains(t, err, "nested deeper than 10 levels")
+}
+
+func TestSchemaTypeJSON(t *testing.T) {
+	var p cli.Property
+	assert.NoError(t, json.Unmarshal([]byte(`{"type": ["string", "null"]}`), &p))
+	assert.Equal(t, []string{"string"}, p.NonNullTypes())
+
+	b, err := json.Marshal(cli.Property{Type: cli.SchemaType{"string"}})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type": "string"}`, string(b))
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// DiscriminatorProperty returns the name of the property used to select a variant of the schema,
+// or an empty string if the schema has no discriminator.
+func (s *Schema) DiscriminatorProperty() string {
+	if s == nil || s.Discriminator == nil {
+		return ""
+	}
+	return s.Discriminator.PropertyName
+}
+
+// Variants returns the oneOf and anyOf branches of the schema keyed by the discriminator value that
+// selects them. If the discriminator has no mapping, values are taken from the const or enum of the
+// discriminator property on each branch.
+func (s *Schema) Variants() map[string]*Schema {
+	name := s.DiscriminatorProperty()
+	if name == "" {
+		return nil
+	}
+
+	variants := map[string]*Schema{}
+	for k, v := range s.Discriminator.Mapping {
+		sch := v
+		variants[k] = &sch
+	}
+	if len(variants) > 0 {
+		return variants
+	}
+
+	branches := append(append([]Schema{}, s.OneOf...), s.AnyOf...)
+	for i := range branches {
+		prop, ok := branches[i].Properties[name]
+		if !ok {
+			continue
+		}
+		for _, v := range propertyValues(prop) {
+			if _, ok := variants[v]; !ok {
+				variants[v] = &branches[i]
+			}
+		}
+	}
+	return variants
+}
+
+// VariantNames returns the sorted list of discriminator values.
+func (s *Schema) VariantNames() []string {
+	variants := s.Variants()
+	names := make([]string, 0, len(variants))
+	for k := range variants {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Variant returns the schema of a single variant, merged with the properties and required fields
+// shared by all the variants.
+func (s *Schema) Variant(value string) (*Schema, error) {
+	variants := s.Variants()
+	v, ok := variants[value]
+	if !ok {
+		return nil, fmt.Errorf("invalid %s '%s'. Possible values: [%s]", s.DiscriminatorProperty(), value, strings.Join(s.VariantNames(), " "))
+	}
+
+	merged := &Schema{
+		Title:       v.Title,
+		Description: v.Description,
+		Properties:  Properties{},
+	}
+	for _, src := range []*Schema{s, v} {
+		for name, prop := range src.Properties {
+			merged.Properties[name] = prop
+		}
+		for _, r := range src.Required {
+			if !slices.Contains(merged.Required, r) {
+				merged.Required = append(merged.Required, r)
+			}
+		}
+	}
+	// Variants could have nested variants too
+	merged.OneOf = v.OneOf
+	merged.AnyOf = v.AnyOf
+	merged.Discriminator = v.Discriminator
+	merged.AdditionalProperties = v.AdditionalProperties
+	if merged.AdditionalProperties == nil {
+		merged.AdditionalProperties = s.AdditionalProperties
+	}
+	return merged, nil
+}
+
+// SelectVariant returns the variant of the schema selected by the discriminator value on body. If
+// the schema has no discriminator or body does not set it, the schema itself is returned.
+func (s *Schema) SelectVariant(body map[string]any) (*Schema, error) {
+	name := s.DiscriminatorProperty()
+	if name == "" {
+		return s, nil
+	}
+
+	value, ok := body[name].(string)
+	if !ok || value == "" {
+		return s, nil
+	}
+	return s.Variant(value)
+}
+
+func propertyValues(prop Property) []string {
+	vals := []string{}
+	if c, ok := prop.Const.(string); ok {
+		vals = append(vals, c)
+	}
+	for _, e := range prop.Enum {
+		if v, ok := e.(string); ok {
+			vals = append(vals, v)
+		}
+	}
+	return vals
+}
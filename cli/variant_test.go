@@ -0,0 +1,62 @@
+package cli_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/stretchr/testify/assert"
+)
+
+const keyGenerateSchema = `{
+	"required": ["type"],
+	"discriminator": {"propertyName": "type"},
+	"properties": {"type": {"type": "string"}},
+	"oneOf": [
+		{
+			"required": ["algorithm"],
+			"additionalProperties": false,
+			"properties": {
+				"type": {"const": "symmetric_key"},
+				"algorithm": {"type": "string", "enum": ["AES-CFB-128", "AES-CFB-256"]}
+			}
+		},
+		{
+			"required": ["algorithm", "purpose"],
+			"properties": {
+				"type": {"const": "asymmetric_key"},
+				"algorithm": {"type": "string", "enum": ["ED25519"]},
+				"purpose": {"type": "string", "enum": ["signing"]}
+			}
+		}
+	]
+}`
+
+func TestSchemaVariants(t *testing.T) {
+	var schema cli.Schema
+	err := json.Unmarshal([]byte(keyGenerateSchema), &schema)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"asymmetric_key", "symmetric_key"}, schema.VariantNames())
+
+	variant, err := schema.SelectVariant(map[string]any{"type": "symmetric_key"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"type", "algorithm"}, variant.Required)
+
+	errs := variant.Validate(map[string]any{"type": "symmetric_key", "algorithm": "AES-CFB-128", "purpose": "signing"})
+	assert.Equal(t, []cli.ValidationError{{Field: "purpose", Message: "unknown field"}}, errs)
+
+	_, err = schema.SelectVariant(map[string]any{"type": "fpe"})
+	assert.Error(t, err)
+
+	variant, err = schema.SelectVariant(map[string]any{})
+	assert.NoError(t, err)
+	assert.Equal(t, &schema, variant)
+}
+
+func TestDiscriminatorMappingRef(t *testing.T) {
+	var d cli.Discriminator
+	err := json.Unmarshal([]byte(`{"propertyName": "type", "mapping": {"secret": "#/components/schemas/Secret"}}`), &d)
+	assert.NoError(t, err)
+	assert.Equal(t, "#/components/schemas/Secret", d.Mapping["secret"].Ref)
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
 
 	pangea "github.com/pangeacyber/pangea-go/pangea-sdk/v3/pangea"
 
@@ -29,6 +33,16 @@ var Services = []string{
 	"embargo",
 	"redact",
 	"file-intel",
+	"audit",
+	"authn",
+	"authz",
+	"ip-intel",
+	"domain-intel",
+	"url-intel",
+	"user-intel",
+	"sanitize",
+	"share",
+	"ai-guard",
 }
 
 var (
@@ -49,7 +63,7 @@ var (
 	// versionCmd represents the version command
 	cleanCacheCmd = &cobra.Command{
 		Use:   "clean",
-		Short: "Remove cached json schema files",
+		Short: "Remove cached json schema files, command indexes and secrets",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			folder, err := cli.GetCacheFolder()
 			if err != nil {
@@ -64,6 +78,203 @@ var (
 		},
 	}
 
+	listCacheCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List cached OpenAPI specs",
+		Long:  fmt.Sprintf("List cached OpenAPI specs. They're revalidated after their TTL, set with %s environment variable (default %s).", cli.CacheTTLEnvVar, cli.DefaultCacheTTL),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := cli.ListCachedSpecs()
+			if err != nil {
+				return err
+			}
+
+			rows := make([]map[string]any, 0, len(entries))
+			for _, e := range entries {
+				rows = append(rows, map[string]any{
+					"service":       e.Service,
+					"url":           e.URL,
+					"source":        e.Source,
+					"version":       e.Version,
+					"size":          e.Size,
+					"fetched_at":    e.FetchedAt,
+					"expired":       e.Expired(),
+					"etag":          e.ETag,
+					"last_modified": e.LastModified,
+					"sha256":        e.SHA256,
+				})
+			}
+			return printRows(cmd, rows, []string{"service", "source", "version", "url", "size", "fetched_at", "expired", "etag", "last_modified", "sha256"})
+		},
+	}
+
+	refreshCacheCmd = &cobra.Command{
+		Use:     "refresh",
+		Short:   "Revalidate cached OpenAPI specs, downloading the ones that changed",
+		Long:    "Revalidate the OpenAPI specs of the given services regardless of their TTL, downloading the ones that changed. If no service is set, all cached specs are refreshed.",
+		Example: "pangea admin cache refresh vault redact",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			urls := []string{}
+			if len(args) == 0 {
+				entries, err := cli.ListCachedSpecs()
+				if err != nil {
+					return err
+				}
+				for _, e := range entries {
+					urls = append(urls, e.URL)
+				}
+			}
+			for _, svc := range args {
+				u, err := serviceSpecURL(svc)
+				if err != nil {
+					return fmt.Errorf("failed to get %s spec URL: %w", svc, err)
+				}
+				urls = append(urls, u)
+			}
+
+			rows := make([]map[string]any, 0, len(urls))
+			failed := 0
+			for _, u := range urls {
+				row := map[string]any{"url": u}
+				entry, changed, err := cli.RefreshSpec(u)
+				switch {
+				case err != nil:
+					row["status"] = "failed"
+					row["error"] = err.Error()
+					failed++
+				case changed:
+					row["status"] = "updated"
+				default:
+					row["status"] = "not modified"
+				}
+				if entry != nil {
+					row["service"] = entry.Service
+					row["version"] = entry.Version
+					row["sha256"] = entry.SHA256
+				}
+				rows = append(rows, row)
+			}
+
+			if err := printRows(cmd, rows, []string{"service", "status", "version", "url", "sha256", "error"}); err != nil {
+				return err
+			}
+			if failed > 0 {
+				return fmt.Errorf("failed to refresh %d of %d specs", failed, len(urls))
+			}
+			return nil
+		},
+	}
+
+	importCacheCmd = &cobra.Command{
+		Use:     "import",
+		Short:   "Import OpenAPI specs from a folder or tar file",
+		Long:    "Import OpenAPI specs from a folder or a tar file, optionally gzipped, with a `<service>.json` spec for each service. They're cached as the specs of the profile domain, and used until they're refreshed or imported again, even without network access.",
+		Example: "pangea admin cache import ./specs.tar.gz",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			bundle, err := cli.ReadSpecBundleFile(args[0])
+			if err != nil {
+				return err
+			}
+			if len(bundle) == 0 {
+				return fmt.Errorf("no specs found on %s", args[0])
+			}
+
+			rows := make([]map[string]any, 0, len(bundle))
+			for _, svc := range bundle.Services() {
+				u, err := serviceSpecURL(svc)
+				if err != nil {
+					return fmt.Errorf("failed to get %s spec URL: %w", svc, err)
+				}
+				entry, err := cli.ImportSpec(u, bundle[svc])
+				if err != nil {
+					return fmt.Errorf("failed to import %s spec: %w", svc, err)
+				}
+				rows = append(rows, map[string]any{
+					"service": svc,
+					"version": entry.Version,
+					"url":     u,
+					"sha256":  entry.SHA256,
+				})
+			}
+			return printRows(cmd, rows, []string{"service", "version", "url", "sha256"})
+		},
+	}
+
+	diffSpecCmd = &cobra.Command{
+		Use:   "diff",
+		Short: "Print the changes on the commands of a service between two versions of its OpenAPI spec",
+		Long: `Print the changes on the commands of a service between its cached OpenAPI spec and a fresh download, without caching it. With '--previous', between the previous cached spec and the current one.
+
+Changes are added or removed commands, added, removed or renamed flags, flag type changes, flags that become required or optional, and added or removed enum values.`,
+		Example: "pangea admin spec diff vault --exit-code\npangea admin spec diff vault --previous -o table",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			u, err := serviceSpecURL(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to get %s spec URL: %w", args[0], err)
+			}
+
+			var oldData, newData []byte
+			if previous, _ := cmd.Flags().GetBool("previous"); previous {
+				if oldData, _, err = cli.PreviousSpecData(u); err != nil {
+					return err
+				}
+				if newData, _, err = cli.CachedSpecData(u); err != nil {
+					return err
+				}
+			} else {
+				if oldData, _, err = cli.CachedSpecData(u); err != nil {
+					return err
+				}
+				if newData, err = cli.DownloadSpec(u); err != nil {
+					return err
+				}
+			}
+
+			oldSpec, err := loadSpecData(oldData, u)
+			if err != nil {
+				return err
+			}
+			newSpec, err := loadSpecData(newData, u)
+			if err != nil {
+				return err
+			}
+
+			changes := builder.DiffSpecs(oldSpec, newSpec)
+			if err := printRows(cmd, changes, []string{"command", "change", "flag", "from", "to", "values"}); err != nil {
+				return err
+			}
+			if exitCode, _ := cmd.Flags().GetBool("exit-code"); exitCode && len(changes) > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%s spec has %d changes", args[0], len(changes))
+			}
+			return nil
+		},
+	}
+
+	servicesCmd = &cobra.Command{
+		Use:   "services",
+		Short: "List the services with commands built from their OpenAPI spec",
+		Long:  fmt.Sprintf("List the services with commands built from their OpenAPI spec, if they're enabled on the profile or %s environment variable, and the version of the spec bundled with the CLI.", cli.ServicesEnvVar),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			enabled := cli.GetEnabledServices(Services)
+			rows := make([]map[string]any, 0, len(Services))
+			for _, svc := range Services {
+				row := map[string]any{
+					"service": svc,
+					"enabled": slices.Contains(enabled, svc),
+				}
+				if bundled := cli.BundledSpec(svc); bundled != nil {
+					row["bundled"] = cli.SpecVersion(bundled)
+				}
+				rows = append(rows, row)
+			}
+			return printRows(cmd, rows, []string{"service", "enabled", "bundled"})
+		},
+	}
+
 	adminCmd = &cobra.Command{
 		Use:     "admin",
 		Short:   "List of 'admin' commands.",
@@ -80,9 +291,18 @@ var (
 )
 
 func main() {
-	_, _, err := updates.CheckAvailableVersion(false)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+	// Set up before anything is requested, as specs are loaded before flags are parsed
+	record, replay := cassetteArgs(os.Args[1:])
+	if err := cli.SetupCassette(record, replay); err != nil {
+		log.Fatal(err)
+	}
+
+	// Replayed sessions should not reach the network
+	if replay == "" {
+		_, _, err := updates.CheckAvailableVersion(false)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
 	}
 
 	rootCmd.AddGroup(
@@ -96,8 +316,24 @@ func main() {
 		},
 	)
 
+	rootCmd.PersistentFlags().StringP(builder.FlagOutput, "o", cli.OutputJSON, fmt.Sprintf("Output format of service commands. Possible values: [%s]", strings.Join(cli.OutputFormats(), " ")))
+	rootCmd.RegisterFlagCompletionFunc(builder.FlagOutput, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) { //nolint:errcheck
+		return cli.OutputFormats(), cobra.ShellCompDirectiveDefault
+	})
+
+	rootCmd.PersistentFlags().String(builder.FlagQuery, "", "JMESPath expression applied to the command result before printing it. Example: --query 'items[].name'")
+
+	rootCmd.PersistentFlags().Bool(builder.FlagVerbose, false, fmt.Sprintf("Print each HTTP request with its status, duration and request ID to stderr. Also enabled with %s environment variable.", cli.DebugEnvVar))
+	rootCmd.PersistentFlags().Bool(builder.FlagTrace, false, "Same as --verbose, also printing request and response headers.")
+	rootCmd.PersistentFlags().String(builder.FlagTraceFile, "", "Save a HAR like JSON log of the HTTP requests to this file. Bodies are not included.")
+	rootCmd.PersistentPreRun = setupTrace
+
+	rootCmd.PersistentFlags().String(builder.FlagRecord, "", "Save the HTTP requests and responses to this cassette file, with tokens and secrets scrubbed.")
+	rootCmd.PersistentFlags().String(builder.FlagReplay, "", "Answer the HTTP requests with the responses saved on this cassette file, without sending them.")
+	rootCmd.MarkFlagsMutuallyExclusive(builder.FlagRecord, builder.FlagReplay)
+
 	b := builder.NewBuilder(rootCmd)
-	err = b.AddCommand([]string{"version"}, versionCmd)
+	err := b.AddCommand([]string{"version"}, versionCmd)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to 'version' command. Error: %v", err)
 	}
@@ -117,6 +353,33 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Failed to 'admin cache clean' command. Error: %v", err)
 	}
 
+	err = b.AddCommand([]string{"admin", "cache", "list"}, listCacheCmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to 'admin cache list' command. Error: %v", err)
+	}
+
+	err = b.AddCommand([]string{"admin", "cache", "refresh"}, refreshCacheCmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to 'admin cache refresh' command. Error: %v", err)
+	}
+
+	err = b.AddCommand([]string{"admin", "cache", "import"}, importCacheCmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to 'admin cache import' command. Error: %v", err)
+	}
+
+	diffSpecCmd.Flags().Bool("previous", false, "Compare the previous cached spec with the current one, instead of the current one with a fresh download.")
+	diffSpecCmd.Flags().Bool("exit-code", false, "Exit with an error if there are changes.")
+	err = b.AddCommand([]string{"admin", "spec", "diff"}, diffSpecCmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to 'admin spec diff' command. Error: %v", err)
+	}
+
+	err = b.AddCommand([]string{"admin", "services"}, servicesCmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to 'admin services' command. Error: %v", err)
+	}
+
 	processServices(b)
 	processPlugins(b)
 
@@ -132,14 +395,92 @@ func main() {
 	}
 
 	err = rootCmd.Execute()
+	if ferr := cli.FlushTrace(); ferr != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write trace file. Error: %v\n", ferr)
+	}
+	var exitErr *cli.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	}
 	if err != nil {
 		log.Fatalf("Error executing root command. %v", err)
 	}
 }
 
+func setupTrace(cmd *cobra.Command, args []string) {
+	flags := builder.CLIFlags(cmd)
+	level := cli.TraceOff
+	if verbose, _ := flags.GetBool(builder.FlagVerbose); verbose {
+		level = cli.TraceVerbose
+	}
+	if trace, _ := flags.GetBool(builder.FlagTrace); trace {
+		level = cli.TraceHeaders
+	}
+	traceFile, _ := flags.GetString(builder.FlagTraceFile)
+	cli.SetupTrace(level, traceFile)
+}
+
+// printRows prints the result of admin commands with the output and query flags. columns are the ones
+// printed by table and CSV formats.
+func printRows(cmd *cobra.Command, rows any, columns []string) error {
+	return builder.Print(cmd, rows, cli.RenderOptions{ListColumns: columns})
+}
+
+// loadSpecData parses a spec downloaded from a URL, failing if it's an error response.
+func loadSpecData(data []byte, u string) (*cli.OpenAPI, error) {
+	oapi, err := cli.LoadReader(bytes.NewReader(data), u)
+	if err != nil {
+		return nil, err
+	}
+	if oapi.Status != nil {
+		return nil, fmt.Errorf("failed to load spec from %s: %s", u, *oapi.Status)
+	}
+	return oapi, nil
+}
+
+// serviceSpecURL returns the URL of the OpenAPI spec of a service, on the domain of the current profile.
+func serviceSpecURL(svc string) (string, error) {
+	token, domain, err := cli.GetTokenAndDomain(svc)
+	if err != nil {
+		return "", err
+	}
+
+	config := cli.GetDefaultPangeaConfig()
+	cli.SetDomain(&config, domain)
+	config.Token = token
+	return pangea.NewClient(svc, &config).GetURL(OpenAPIPath)
+}
+
+// cassetteArgs returns the values of the record and replay flags, looking for them on args as they're
+// needed before the commands are built.
+func cassetteArgs(args []string) (record, replay string) {
+	for n := 0; n < len(args); n++ {
+		name, value, hasValue := strings.Cut(args[n], "=")
+		if name == "--" {
+			break
+		}
+		if name != "--"+builder.FlagRecord && name != "--"+builder.FlagReplay {
+			continue
+		}
+		if !hasValue && n+1 < len(args) {
+			n++
+			value = args[n]
+		}
+
+		if name == "--"+builder.FlagRecord {
+			record = value
+		} else {
+			replay = value
+		}
+	}
+	return record, replay
+}
+
 func processServices(b *builder.Builder) {
 	errorPrinted := false
-	for _, svc := range Services {
+	specs := []*serviceSpec{}
+	invoked := invokedCommand(os.Args[1:])
+	for _, svc := range cli.GetEnabledServices(Services) {
 		token, domain, err := cli.GetTokenAndDomain(svc)
 		if err != nil {
 			if errorPrinted {
@@ -151,7 +492,7 @@ func processServices(b *builder.Builder) {
 		}
 
 		config := cli.GetDefaultPangeaConfig()
-		config.Domain = domain
+		cli.SetDomain(&config, domain)
 		config.Token = token
 
 		svcCmd := &cobra.Command{
@@ -160,22 +501,123 @@ func processServices(b *builder.Builder) {
 			GroupID: "services",
 		}
 
-		svcCmd.PersistentFlags().String("cli-profile", "", "Run API call with a particular CLI profile token and domain. Setup profile with 'pangea admin profile' commands.")
+		svcCmd.PersistentFlags().String(builder.FlagCLIProfile, "", "Run API call with a particular CLI profile token and domain. Setup profile with 'pangea admin profile' commands.")
+		svcCmd.PersistentFlags().String(builder.FlagBody, "", "JSON request body. Use '-' to read it from stdin. Flags override its fields.")
+		svcCmd.PersistentFlags().String(builder.FlagBodyFile, "", "Path to a JSON file with the request body. Use '-' to read it from stdin. Flags override its fields.")
+		svcCmd.PersistentFlags().Bool(builder.FlagNoValidate, false, "Skip client side validation of the request against the service schema.")
+		svcCmd.PersistentFlags().Bool(builder.FlagDryRun, false, "Print the HTTP request, with the token masked, instead of sending it.")
+		svcCmd.PersistentFlags().Bool(builder.FlagAsync, false, "Do not wait for queued requests. Their request ID is printed so their result could be collected later with 'pangea request' commands.")
+		svcCmd.PersistentFlags().Duration(builder.FlagPollTimeout, config.PollResultTimeout, "Maximum time to wait for the result of queued requests.")
+		svcCmd.PersistentFlags().String(builder.FlagBatch, "", "Send a request for each line of a JSON lines file, or each row of a CSV file with flag names as header. Use '-' to read JSON lines from stdin. Flags set fields common to all requests. Results are printed as JSON lines.")
+		svcCmd.PersistentFlags().Int(builder.FlagConcurrency, builder.DefaultBatchConcurrency, fmt.Sprintf("Number of '--%s' requests sent at the same time.", builder.FlagBatch))
+		svcCmd.PersistentFlags().Bool(builder.FlagAsCurl, false, "Print an equivalent curl command instead of sending the request. The token is read from PANGEA_TOKEN environment variable.")
+
+		// Services commands are only needed for the invoked service. The others are added without them,
+		// so they're listed on help.
+		if !needsSpec(invoked, svc) {
+			err = b.AddCommand([]string{svc}, svcCmd)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to add service[%s]. Error: %v", svc, err)
+			}
+			continue
+		}
+		specs = append(specs, &serviceSpec{svc: svc, config: &config, cmd: svcCmd})
+	}
+
+	loadServiceSpecs(specs)
+	for _, spec := range specs {
+		if spec.err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to process %s service json schema. Error: %v.\n", spec.svc, spec.err)
+			continue
+		}
+		b.AddIndexedCommands(spec.config, spec.svc, spec.index)
+		if spec.entry != nil {
+			spec.cmd.Long = fmt.Sprintf("%s.\n\nOpenAPI spec %s.", spec.cmd.Short, spec.entry.Origin())
+		}
 
-		err = processServiceJsonSchema(b, svc, &config)
+		err := b.AddCommand([]string{spec.svc}, spec.cmd)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Unable to process %s service json schema. Error: %v.\n", svc, err)
+			fmt.Fprintf(os.Stderr, "Failed to add service[%s]. Error: %v", spec.svc, err)
 			continue
 		}
 
-		err = b.AddCommand([]string{svc}, svcCmd)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to add service[%s]. Error: %v", svc, err)
+		loadedServices[spec.svc] = true
+	}
+}
+
+// Root flags that take a value, so it's not taken as a command name
+var rootValueFlags = map[string]bool{
+	"--" + builder.FlagOutput:    true,
+	"-o":                         true,
+	"--" + builder.FlagQuery:     true,
+	"--" + builder.FlagTraceFile: true,
+	"--" + builder.FlagRecord:    true,
+	"--" + builder.FlagReplay:    true,
+}
+
+// invokedCommand returns the names of the command invoked by args, like `[vault v1 /list]`. Shell
+// completion and help requests return the command they're about.
+func invokedCommand(args []string) []string {
+	names := []string{}
+	for n := 0; n < len(args); n++ {
+		arg := args[n]
+		if arg == "--" {
+			break
+		}
+		if strings.HasPrefix(arg, "-") && arg != "-" {
+			if rootValueFlags[arg] {
+				n++
+			}
 			continue
 		}
+		names = append(names, arg)
+	}
+
+	if len(names) > 0 && (names[0] == "help" || names[0] == cobra.ShellCompRequestCmd || names[0] == cobra.ShellCompNoDescRequestCmd) {
+		names = names[1:]
+	}
+	return names
+}
+
+// needsSpec returns if the commands of svc have to be built from its spec to run the invoked command.
+// That's the case unless another service or a plugin command of svc, like `vault workspace`, is invoked.
+func needsSpec(invoked []string, svc string) bool {
+	if len(invoked) == 0 || invoked[0] != svc {
+		return false
+	}
+	if len(invoked) == 1 {
+		return true
+	}
+
+	for _, p := range loader.LoadPlugins() {
+		path := p.GetCommandPath()
+		if p.GetServiceAssociated() == "" && len(path) > 1 && path[0] == svc && path[1] == invoked[1] {
+			return false
+		}
+	}
+	return true
+}
+
+type serviceSpec struct {
+	svc    string
+	config *pangea.Config
+	cmd    *cobra.Command
+	index  *builder.CommandIndex
+	entry  *cli.SpecCacheEntry
+	err    error
+}
 
-		loadedServices[svc] = true
+// loadServiceSpecs loads the command indexes of the services at the same time.
+func loadServiceSpecs(specs []*serviceSpec) {
+	var wg sync.WaitGroup
+	for _, spec := range specs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			spec.index, spec.entry, spec.err = loadServiceSpec(spec.svc, spec.config)
+		}()
 	}
+	wg.Wait()
 }
 
 func processPlugins(b *builder.Builder) {
@@ -199,17 +641,44 @@ func processPlugins(b *builder.Builder) {
 	}
 }
 
-func processServiceJsonSchema(b *builder.Builder, svc string, config *pangea.Config) error {
+// loadServiceSpec returns the command index of a service and the metadata of its cached spec, if
+// any. The index is built from the spec and saved to cache the first time the spec is used.
+func loadServiceSpec(svc string, config *pangea.Config) (*builder.CommandIndex, *cli.SpecCacheEntry, error) {
 	client := pangea.NewClient(svc, config)
 
 	oapiURL, err := client.GetURL(OpenAPIPath)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	oapi, err := cli.LoadURL(oapiURL)
+	// Indexes of fresh cached specs are looked up by the hash on their metadata, without reading the spec.
+	// Cassettes skip the cache.
+	if cli.GetCassetteMode() == cli.CassetteOff {
+		if entry := cli.CachedSpecMetadata(oapiURL); entry != nil && !entry.Expired() {
+			if idx := builder.LoadCommandIndex(entry.SHA256); idx != nil {
+				return idx, entry, nil
+			}
+		}
+	}
+
+	data, err := cli.LoadSpec(oapiURL)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+
+	// Replayed specs may not match the cached ones
+	entry := cli.CachedSpec(oapiURL)
+	hash := cli.SpecHash(data)
+	if entry != nil && entry.SHA256 != hash {
+		entry = nil
+	}
+	if idx := builder.LoadCommandIndex(hash); idx != nil {
+		return idx, entry, nil
+	}
+
+	oapi, err := cli.LoadReader(bytes.NewReader(data), oapiURL)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	if oapi.Status != nil && *oapi.Status == "Unauthorized" {
@@ -219,18 +688,12 @@ func processServiceJsonSchema(b *builder.Builder, svc string, config *pangea.Con
 			fmt.Fprintf(os.Stderr, "Failed to remove cached file from URL. Error: %v", err)
 		}
 
-		return cli.ErrUnauthorized
+		return nil, nil, cli.ErrUnauthorized
 	}
 
-	// add all commands (keep version version)
-	for path, pathDef := range oapi.Paths {
-		pathOri := path
-		version := ""
-		ndx := strings.Index(path[1:], "/")
-		if ndx >= 0 {
-			version = path[1 : ndx+1]
-		}
-		b.AddPangeaCommand(config, svc, path[ndx+1:], pathOri, pathDef.Post, version)
+	idx := builder.NewCommandIndex(oapi, hash)
+	if cli.GetCassetteMode() != cli.CassetteReplay {
+		_ = builder.SaveCommandIndex(idx)
 	}
-	return nil
+	return idx, entry, nil
 }
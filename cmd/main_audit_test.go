@@ -0,0 +1,22 @@
+package main_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditSearch(t *testing.T) {
+	// The request `query` field shadows the CLI flag with the same name, so it's not taken as JMESPath
+	r := run("audit", "v1", "/search", "--query", "actor:alice")
+	assert.Equal(t, float64(2), r["count"])
+	events := r["events"].([]any)
+	event := events[0].(map[string]any)["envelope"].(map[string]any)["event"].(map[string]any)
+	assert.Equal(t, "login", event["action"])
+
+	r = run("audit", "v1", "/search", "--query", "actor:alice", "--order", "desc", "--limit", "1")
+	assert.Equal(t, float64(1), r["count"])
+	events = r["events"].([]any)
+	event = events[0].(map[string]any)["envelope"].(map[string]any)["event"].(map[string]any)
+	assert.Equal(t, "delete", event["action"])
+}
@@ -1,6 +1,8 @@
 package main_test
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -35,3 +37,29 @@ func TestEmbargoISOcheck(t *testing.T) {
 		assert.NotEmpty(t, sanction["issuing_country"].(string))
 	}
 }
+
+func TestEmbargoISOcheckCSV(t *testing.T) {
+	output := runRaw("embargo", "v1", "/iso/check", "--iso_code", "CU", "--output", "csv")
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	assert.Equal(t, len(lines), 2)
+	// Columns are the properties of the items on the response schema
+	assert.Equal(t, "annotations,embargoed_country_iso_code,embargoed_country_name,issuing_country,list_name", lines[0])
+	assert.Contains(t, lines[1], "CU")
+}
+
+func TestEmbargoISOcheckBatch(t *testing.T) {
+	for _, file := range []string{"testdata/embargo_batch.jsonl", "testdata/embargo_batch.csv"} {
+		output := runRaw("embargo", "v1", "/iso/check", "--batch", file, "--concurrency", "2")
+		lines := strings.Split(strings.TrimSpace(output), "\n")
+		assert.Equal(t, len(lines), 2)
+
+		codes := []string{"CU", "IR"}
+		for i, l := range lines {
+			var r map[string]any
+			assert.NoError(t, json.Unmarshal([]byte(l), &r))
+			assert.NotEmpty(t, r["line"])
+			assert.Equal(t, r["status"], "Success")
+			assert.Contains(t, l, codes[i])
+		}
+	}
+}
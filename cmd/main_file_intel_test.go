@@ -1,7 +1,12 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -51,3 +56,53 @@ func TestFileIntelReputationPattern(t *testing.T) {
 	data := r["data"].(map[string]any)
 	assert.NotEmpty(t, data)
 }
+
+func TestFileIntelReputationVerbose(t *testing.T) {
+	// The request `verbose` field shadows the CLI flag with the same name
+	hash := "142b638c6a60b60c7f9928da4fb85a5a8e1422a9ffdc9ee49e17e56ccca9cf6e"
+	r := run("file-intel", "v1", "/reputation", "--hash_type", "sha256", "--hash", hash, "--verbose", "true")
+
+	params := r["parameters"].(map[string]any)
+	assert.Equal(t, true, params["verbose"])
+	assert.Equal(t, hash, params["hash"])
+
+	r = run("file-intel", "v1", "/reputation", "--hash_type", "sha256", "--hash", hash)
+	assert.NotContains(t, r, "parameters")
+}
+
+func TestFileIntelFileField(t *testing.T) {
+	// Import a spec with a request field named `file`. Only multipart endpoints have the `file` upload flag.
+	b, err := os.ReadFile(filepath.Join("..", "internal", "mockpangea", "specs", "file-intel.json"))
+	assert.NoError(t, err)
+	var spec map[string]any
+	assert.NoError(t, json.Unmarshal(b, &spec))
+	props := spec["paths"].(map[string]any)["/v1/reputation"].(map[string]any)["post"].(map[string]any)["requestBody"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)["properties"].(map[string]any)
+	props["file"] = map[string]any{"type": "string", "description": "Name of the file the hash was taken from."}
+
+	bundle := t.TempDir()
+	b, err = json.Marshal(spec)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(bundle, "file-intel.json"), b, 0644))
+	home := t.TempDir()
+	run := func(args ...string) string {
+		cmd := exec.Command("./"+pangeaCLICommand, args...)
+		cmd.Env = append(os.Environ(), "HOME="+home)
+		out, err := cmd.Output()
+		assert.NoError(t, err, string(out))
+		return string(out)
+	}
+	run("admin", "cache", "import", bundle)
+
+	hash := "142b638c6a60b60c7f9928da4fb85a5a8e1422a9ffdc9ee49e17e56ccca9cf6e"
+	out := run("file-intel", "v1", "/reputation", "--hash_type", "sha256", "--hash", hash, "--file", "report.pdf", "--verbose", "true", "--output", "json-compact")
+	var r map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(out), &r))
+	assert.Equal(t, "report.pdf", r["parameters"].(map[string]any)["file"])
+
+	batch := filepath.Join(t.TempDir(), "batch.csv")
+	assert.NoError(t, os.WriteFile(batch, []byte("hash,file\n"+hash+",report.pdf\n"), 0644))
+	out = run("file-intel", "v1", "/reputation", "--hash_type", "sha256", "--verbose", "true", "--batch", batch)
+	assert.Equal(t, 1, strings.Count(out, "\n"))
+	assert.NoError(t, json.Unmarshal([]byte(out), &r))
+	assert.Equal(t, "report.pdf", r["result"].(map[string]any)["parameters"].(map[string]any)["file"])
+}
@@ -1,6 +1,10 @@
 package main_test
 
 import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -23,3 +27,73 @@ func TestRedactStructured(t *testing.T) {
 	assert.Equal(t, int(r["count"].(float64)), 1)
 	assert.Equal(t, r["redacted_data"].(map[string]any), redacted)
 }
+
+func TestRedactStructuredBody(t *testing.T) {
+	body := `{"data": {"phone": "415-867-5309", "name": "John"}}`
+	redacted := map[string]any{"phone": "<PHONE_NUMBER>", "name": "John"}
+
+	r := run("redact", "v1", "/redact_structured", "--body", body)
+	assert.Equal(t, int(r["count"].(float64)), 1)
+	assert.Equal(t, r["redacted_data"].(map[string]any), redacted)
+}
+
+func TestRedactBodyFileOverride(t *testing.T) {
+	redacted := "My Phone number is <PHONE_NUMBER>"
+
+	r := run("redact", "v1", "/redact", "--body-file", "testdata/redact.json", "--text", "My Phone number is 415-867-5309")
+	assert.Equal(t, int(r["count"].(float64)), 1)
+	assert.Equal(t, r["redacted_text"].(string), redacted)
+}
+
+func TestRedactMissingFile(t *testing.T) {
+	cmd := exec.Command("./"+pangeaCLICommand, "redact", "v1", "/redact", "--text", "@testdata/missing.txt", "--dry-run")
+	out, err := cmd.CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(out), "failed to read file for --text: open testdata/missing.txt: no such file or directory")
+	assert.NotContains(t, string(out), "POST ")
+}
+
+func TestRedactDryRun(t *testing.T) {
+	out := runRaw("redact", "v1", "/redact", "--text", "@testdata/redact.txt", "--dry-run")
+	assert.Regexp(t, `^POST `+domainScheme()+`://redact\.`, out)
+	assert.Contains(t, out, "/v1/redact\n")
+	assert.Contains(t, out, "Authorization: Bearer ")
+	assert.Contains(t, out, `"text": "My Phone number is 415-867-5309`)
+}
+
+func TestRedactAsCurl(t *testing.T) {
+	out := runRaw("redact", "v1", "/redact", "--text", "it's a test", "--as-curl")
+	assert.Regexp(t, `^curl -X POST '`+domainScheme()+`://redact\.`, out)
+	assert.Contains(t, out, `-H "Authorization: Bearer $PANGEA_TOKEN"`)
+	assert.Contains(t, out, `--data-raw '{"text":"it'\''s a test"}'`)
+}
+
+func TestRedactRecordReplay(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+	input := "My Phone number is 415-867-5309"
+
+	recorded := run("--record", cassette, "redact", "v1", "/redact", "--text", input)
+	assert.Equal(t, "My Phone number is <PHONE_NUMBER>", recorded["redacted_text"])
+
+	b, err := os.ReadFile(cassette)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), os.Getenv("PANGEA_TOKEN"))
+
+	// Replay without credentials nor network access
+	cmd := exec.Command("./"+pangeaCLICommand, "--replay", cassette, "redact", "v1", "/redact", "--text", input)
+	cmd.Env = append(os.Environ(),
+		"HOME="+t.TempDir(),
+		"PANGEA_TOKEN=",
+		"PANGEA_DOMAIN=",
+		"HTTP_PROXY=http://127.0.0.1:1",
+		"HTTPS_PROXY=http://127.0.0.1:1",
+		"http_proxy=http://127.0.0.1:1",
+		"https_proxy=http://127.0.0.1:1",
+	)
+	out, err := cmd.Output()
+	assert.NoError(t, err)
+
+	var replayed map[string]any
+	assert.NoError(t, json.Unmarshal(out, &replayed))
+	assert.Equal(t, recorded, replayed)
+}
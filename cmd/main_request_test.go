@@ -0,0 +1,58 @@
+package main_test
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Hashes queued by the mock File Intel service. The first one is ready after being polled once, and the
+// second one is never ready.
+const (
+	queuedHash  = "1de7f7e7a4cd3a0b1e0ed6e2a5f0b1d1e1c2f6f0d1b8e2f3a4c5d6e7f8091a2b"
+	pendingHash = "9b3c1a7f2e5d4c6b8a0f1e2d3c4b5a69788796a5b4c3d2e1f0a9b8c7d6e5f4a3"
+)
+
+func TestQueuedRequestPolled(t *testing.T) {
+	// Without --async, the result is polled until it's ready
+	r := run("file-intel", "v1", "/reputation", "--hash_type", "sha256", "--hash", queuedHash)
+	assert.Equal(t, "unknown", r["data"].(map[string]any)["verdict"])
+}
+
+func TestAsyncRequestPoll(t *testing.T) {
+	cmd := exec.Command("./"+pangeaCLICommand, "file-intel", "v1", "/reputation", "--hash_type", "sha256", "--hash", queuedHash, "--async")
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+	assert.Contains(t, string(out), "Request accepted. Get its result running 'pangea request wait prq_")
+
+	r := run("file-intel", "v1", "/reputation", "--hash_type", "sha256", "--hash", queuedHash, "--async")
+	assert.Equal(t, "Accepted", r["status"])
+	id := r["request_id"].(string)
+	assert.NotEmpty(t, id)
+
+	r = run("request", "poll", id, "--service", "file-intel")
+	assert.Equal(t, map[string]any{"request_id": id, "status": "Accepted"}, r)
+
+	r = run("request", "poll", id, "--service", "file-intel")
+	assert.Equal(t, "unknown", r["data"].(map[string]any)["verdict"])
+}
+
+func TestAsyncRequestWait(t *testing.T) {
+	r := run("file-intel", "v1", "/reputation", "--hash_type", "sha256", "--hash", queuedHash, "--async")
+	assert.Equal(t, "Accepted", r["status"])
+
+	r = run("request", "wait", r["request_id"].(string), "--service", "file-intel")
+	assert.Equal(t, "unknown", r["data"].(map[string]any)["verdict"])
+}
+
+func TestAsyncRequestWaitTimeout(t *testing.T) {
+	r := run("file-intel", "v1", "/reputation", "--hash_type", "sha256", "--hash", pendingHash, "--async")
+	assert.Equal(t, "Accepted", r["status"])
+	id := r["request_id"].(string)
+
+	cmd := exec.Command("./"+pangeaCLICommand, "request", "wait", id, "--service", "file-intel", "--poll-timeout", "2s")
+	out, err := cmd.CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(out), "request "+id+" is still in progress after 2s")
+}
@@ -0,0 +1,38 @@
+package main_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeUpload(t *testing.T) {
+	content, err := os.ReadFile("testdata/test1.txt")
+	assert.NoError(t, err)
+	sum := sha256.Sum256(content)
+
+	r := run("sanitize", "v1", "/sanitize", "--file", "testdata/test1.txt")
+	params := r["parameters"].(map[string]any)
+	assert.Equal(t, "test1.txt", params["file_name"])
+	assert.Equal(t, len(content), int(params["size"].(float64)))
+	assert.Equal(t, hex.EncodeToString(sum[:]), params["sha256"])
+	assert.Equal(t, false, r["data"].(map[string]any)["malicious_file"])
+}
+
+func TestSanitizeUploadDryRun(t *testing.T) {
+	out := runRaw("sanitize", "v1", "/sanitize", "--file", "testdata/test1.txt", "--dry-run")
+	assert.Contains(t, out, "Content-Type: multipart/form-data\n")
+	assert.Contains(t, out, `"transfer_method": "multipart"`)
+	assert.Contains(t, out, "upload (file testdata/test1.txt,")
+}
+
+func TestSanitizeUploadAsCurl(t *testing.T) {
+	out := runRaw("sanitize", "v1", "/sanitize", "--file", "testdata/test1.txt", "--as-curl")
+	assert.Contains(t, out, `-F 'request="{\"crc32c\":`)
+	assert.Contains(t, out, `;type=application/json'`)
+	assert.Contains(t, out, `-F 'upload=@"testdata/test1.txt"'`)
+	assert.NotContains(t, out, "Content-Type")
+}
@@ -0,0 +1,244 @@
+package main_test
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/stretchr/testify/assert"
+)
+
+// downloadedSpecs runs the CLI with an empty cache and returns the hosts whose spec was downloaded.
+func downloadedSpecs(t *testing.T, args ...string) []string {
+	cmd := exec.Command("./"+pangeaCLICommand, args...)
+	cmd.Env = append(os.Environ(), "HOME="+t.TempDir(), cli.DebugEnvVar+"=1")
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+
+	hosts := []string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if !strings.HasPrefix(line, "GET ") || !strings.Contains(line, "/v1/openapi.json") {
+			continue
+		}
+		host := strings.TrimPrefix(strings.Fields(line)[1], "http://")
+		host = strings.TrimPrefix(host, "https://")
+		hosts = append(hosts, strings.Split(host, ".")[0])
+	}
+	return hosts
+}
+
+func TestStartupLoadsInvokedServiceOnly(t *testing.T) {
+	assert.Empty(t, downloadedSpecs(t, "version"))
+	assert.Empty(t, downloadedSpecs(t, "--help"))
+	assert.Empty(t, downloadedSpecs(t, "vault", "workspace", "--help"))
+	assert.Equal(t, []string{"redact"}, downloadedSpecs(t, "redact", "v1", "/redact", "--text", "test", "--dry-run"))
+	assert.Equal(t, []string{"vault"}, downloadedSpecs(t, "-o", "yaml", "vault", "--help"))
+}
+
+func BenchmarkStartup(b *testing.B) {
+	commands := map[string][]string{
+		"version": {"version"},
+		"plugin":  {"vault", "workspace", "--help"},
+		"service": {"redact", "v1", "/redact", "--text", "test", "--dry-run"},
+	}
+
+	for name, args := range commands {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := exec.Command("./"+pangeaCLICommand, args...).Run(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCommandIndex(t *testing.T) {
+	home := t.TempDir()
+	runWithHome := func(args ...string) string {
+		cmd := exec.Command("./"+pangeaCLICommand, args...)
+		cmd.Env = append(os.Environ(), "HOME="+home)
+		out, err := cmd.Output()
+		assert.NoError(t, err)
+		return string(out)
+	}
+
+	// First run builds the index from the spec, and the second one builds the commands from it
+	help := runWithHome("vault", "v1", "/key/generate", "--help")
+	assert.Contains(t, help, "--purpose")
+	assert.Equal(t, help, runWithHome("vault", "v1", "/key/generate", "--help"))
+
+	index, err := filepath.Glob(filepath.Join(home, ".pangea", "cache", "index", "*_"+cli.Version+".json"))
+	assert.NoError(t, err)
+	assert.Len(t, index, 1)
+	b, err := os.ReadFile(index[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"name":"purpose","type":"string (enum)"`)
+
+	runWithHome("admin", "cache", "clean")
+	assert.NoFileExists(t, index[0])
+}
+
+func TestCacheListRefresh(t *testing.T) {
+	home := t.TempDir()
+	runWithHome := func(args ...string) string {
+		cmd := exec.Command("./"+pangeaCLICommand, args...)
+		cmd.Env = append(os.Environ(), "HOME="+home)
+		out, err := cmd.Output()
+		assert.NoError(t, err)
+		return string(out)
+	}
+
+	runWithHome("vault", "--help")
+	list := runWithHome("admin", "cache", "list", "-o", "json")
+	assert.Contains(t, list, `"service": "vault"`)
+	assert.Contains(t, list, `"etag"`)
+
+	// Mock server answers with the same ETag, so the spec is revalidated but not downloaded again
+	refresh := runWithHome("admin", "cache", "refresh", "vault", "-o", "json")
+	assert.Contains(t, refresh, `"status": "not modified"`)
+}
+
+func TestCacheImport(t *testing.T) {
+	home := t.TempDir()
+	bundle := t.TempDir()
+	spec, err := os.ReadFile(filepath.Join("..", "internal", "mockpangea", "specs", "embargo.json"))
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(bundle, "embargo.json"), spec, 0644))
+
+	// Nothing listens on the proxy, so specs could not be downloaded
+	env := append(os.Environ(), "HOME="+home, "HTTP_PROXY=http://127.0.0.1:1", "http_proxy=http://127.0.0.1:1")
+	cmd := exec.Command("./"+pangeaCLICommand, "admin", "cache", "import", bundle)
+	cmd.Env = env
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+	assert.Contains(t, string(out), `"service": "embargo"`)
+
+	cmd = exec.Command("./"+pangeaCLICommand, "embargo", "--help")
+	cmd.Env = append(env, cli.CacheTTLEnvVar+"=0s")
+	out, err = cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+	assert.Contains(t, string(out), "OpenAPI spec version 1.0.0, imported at")
+	assert.Contains(t, string(out), "v1")
+}
+
+// Home folder of the user running the tests, before TestMain replaces it, so the Go build cache is used
+var userHome, _ = os.UserHomeDir()
+
+// buildWithBundle builds the CLI with the mock specs of the given services embedded on cli/bundle, as
+// release builds do with the downloaded ones.
+func buildWithBundle(t *testing.T, services ...string) string {
+	replace := map[string]string{}
+	for _, svc := range services {
+		bundled, err := filepath.Abs(filepath.Join("..", "cli", "bundle", svc+".json"))
+		assert.NoError(t, err)
+		spec, err := filepath.Abs(filepath.Join("..", "internal", "mockpangea", "specs", svc+".json"))
+		assert.NoError(t, err)
+		replace[bundled] = spec
+	}
+	b, err := json.Marshal(map[string]any{"Replace": replace})
+	assert.NoError(t, err)
+	overlay := filepath.Join(t.TempDir(), "overlay.json")
+	assert.NoError(t, os.WriteFile(overlay, b, 0644))
+
+	bin := filepath.Join(t.TempDir(), pangeaCLICommand)
+	cmd := exec.Command("go", "build", "-overlay", overlay, "-o", bin, "./main.go")
+	cmd.Env = append(os.Environ(), "HOME="+userHome, "USERPROFILE="+userHome)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("failed to build the CLI with bundled specs: %v. Output: %s", err, out)
+	}
+	return bin
+}
+
+func TestBundledSpecFallback(t *testing.T) {
+	type service struct {
+		Service string `json:"service"`
+		Enabled bool   `json:"enabled"`
+		Bundled string `json:"bundled"`
+	}
+
+	bin := buildWithBundle(t, "vault", "embargo")
+	out, err := exec.Command(bin, "admin", "services", "-o", "json").Output()
+	assert.NoError(t, err)
+	var services []service
+	assert.NoError(t, json.Unmarshal(out, &services))
+	enabled := map[string]bool{}
+	for _, svc := range services {
+		enabled[svc.Service] = svc.Enabled
+	}
+	// Tests only enable some services
+	assert.Equal(t, true, enabled["vault"])
+	assert.Equal(t, false, enabled["authn"])
+
+	bundled := []string{}
+	for _, svc := range services {
+		if svc.Bundled == "" || !svc.Enabled {
+			continue
+		}
+		bundled = append(bundled, svc.Service)
+
+		// Nothing listens on the proxy, so the spec could not be downloaded
+		cmd := exec.Command(bin, svc.Service, "--help")
+		cmd.Env = append(os.Environ(), "HOME="+t.TempDir(), "HTTP_PROXY=http://127.0.0.1:1", "http_proxy=http://127.0.0.1:1")
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+		assert.Contains(t, string(out), "Using the one bundled with the CLI", svc.Service)
+		assert.Contains(t, string(out), "OpenAPI spec version "+svc.Bundled+", bundled with the CLI", svc.Service)
+	}
+	if len(bundled) == 0 {
+		t.Fatal("no specs bundled with the CLI")
+	}
+	assert.ElementsMatch(t, []string{"vault", "embargo"}, bundled)
+}
+
+func TestSpecDiff(t *testing.T) {
+	home := t.TempDir()
+	run := func(args ...string) (string, error) {
+		cmd := exec.Command("./"+pangeaCLICommand, args...)
+		cmd.Env = append(os.Environ(), "HOME="+home)
+		out, err := cmd.Output()
+		return string(out), err
+	}
+
+	_, err := run("embargo", "--help")
+	assert.NoError(t, err)
+	out, err := run("admin", "spec", "diff", "embargo", "--exit-code")
+	assert.NoError(t, err)
+	assert.Equal(t, "[]\n", out)
+
+	// Import a newer spec with a renamed flag, a removed command and a new one
+	b, err := os.ReadFile(filepath.Join("..", "internal", "mockpangea", "specs", "embargo.json"))
+	assert.NoError(t, err)
+	var spec map[string]any
+	assert.NoError(t, json.Unmarshal(b, &spec))
+	paths := spec["paths"].(map[string]any)
+	schema := paths["/v1/ip/check"].(map[string]any)["post"].(map[string]any)["requestBody"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)
+	props := schema["properties"].(map[string]any)
+	props["address"] = props["ip"]
+	delete(props, "ip")
+	schema["required"] = []string{"address"}
+	paths["/v2/ip/check"] = paths["/v1/iso/check"]
+	delete(paths, "/v1/iso/check")
+
+	bundle := t.TempDir()
+	b, err = json.Marshal(spec)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(bundle, "embargo.json"), b, 0644))
+	_, err = run("admin", "cache", "import", bundle)
+	assert.NoError(t, err)
+
+	out, err = run("admin", "spec", "diff", "embargo", "--previous", "--exit-code", "-o", "json-compact")
+	assert.Error(t, err)
+	var changes []map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(out), &changes), out)
+	assert.Equal(t, []map[string]any{
+		{"change": "flag_renamed", "command": "v1 /ip/check", "flag": "address", "from": "ip"},
+		{"change": "command_removed", "command": "v1 /iso/check"},
+		{"change": "command_added", "command": "v2 /ip/check"},
+	}, changes)
+}
@@ -4,30 +4,53 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
 	"testing"
 
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/pangeacyber/pangea-cli/v2/internal/mockpangea"
 	"github.com/stretchr/testify/assert"
 )
 
 const pangeaCLICommand = "pangea"
 
+// Set this environment variable to run the tests against the Pangea project of the CLI config, or
+// PANGEA_TOKEN and PANGEA_DOMAIN, instead of the mock server.
+const liveTestsEnvVar = "PANGEA_CLI_TEST_LIVE"
+
+// Domain used to reach the mock server. Requests are sent to it through the HTTP proxy settings.
+const mockDomain = "http://pangea.test"
+
 func TestMain(m *testing.M) {
 	// Run setup code here
 	setUp()
+	teardown := func() {}
+	if os.Getenv(liveTestsEnvVar) == "" {
+		teardown = setUpMockServer()
+	}
 
 	// Run tests
 	exitCode := m.Run()
 
 	// Run teardown code here
+	teardown()
 
 	// Exit with the test exit code
 	os.Exit(exitCode)
 }
 
+// domainScheme returns the scheme services are reached with. The mock server is reached without TLS.
+func domainScheme() string {
+	if os.Getenv(liveTestsEnvVar) != "" {
+		return "https"
+	}
+	return "http"
+}
+
 func printArgs(args ...string) string {
 	r := ""
 	for _, a := range args {
@@ -57,6 +80,38 @@ func setUp() {
 	}
 }
 
+// setUpMockServer starts the mock Pangea server and points the CLI to it, using an empty home folder so
+// the user config and cache are not used. It returns a function to stop it.
+func setUpMockServer() func() {
+	server := httptest.NewServer(mockpangea.New(""))
+
+	home, err := os.MkdirTemp("", "pangea-cli-test")
+	if err != nil {
+		log.Fatalf("Error creating test home folder: %v.\n", err)
+	}
+
+	env := map[string]string{
+		"HOME":          home,
+		"USERPROFILE":   home,
+		"PANGEA_TOKEN":  mockpangea.DefaultToken,
+		"PANGEA_DOMAIN": mockDomain,
+		"HTTP_PROXY":    server.URL,
+		"HTTPS_PROXY":   server.URL,
+		"NO_PROXY":      "",
+	}
+	// Only the services implemented by the mock server
+	os.Setenv(cli.ServicesEnvVar, "vault,audit,embargo,redact,file-intel,sanitize")
+	for k, v := range env {
+		os.Setenv(k, v)
+		os.Setenv(strings.ToLower(k), v)
+	}
+
+	return func() {
+		server.Close()
+		os.RemoveAll(home)
+	}
+}
+
 func runRaw(args ...string) string {
 	cmd := exec.Command("./"+pangeaCLICommand, args...)
 	output, err := cmd.Output()
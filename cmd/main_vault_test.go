@@ -1,7 +1,15 @@
 package main_test
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -135,3 +143,224 @@ func TestStoreUsingFile(t *testing.T) {
 	assert.NotEmpty(t, id)
 	assert.Equal(t, int(r["version"].(float64)), 1)
 }
+
+func TestListQuery(t *testing.T) {
+	output := runRaw("vault", "v1", "/list", "--order", "asc", "--order_by", "id", "--query", "items[].id", "--output", "json-compact")
+	var ids []string
+	err := json.Unmarshal([]byte(output), &ids)
+	assert.NoError(t, err)
+	assert.True(t, len(ids) > 0)
+}
+
+func TestWorkspaceRunTemplate(t *testing.T) {
+	workspace := fmt.Sprintf("run_%d", time.Now().UnixMilli())
+	folder := "/" + workspace + "/"
+	r := run("vault", "v1", "/secret/store", "--type", "secret", "--secret", "pass1", "--name", "DB_PASSWORD", "--folder", folder)
+	dbID := r["id"].(string)
+	run("vault", "v1", "/secret/rotate", "--id", dbID, "--secret", "pass2")
+	r = run("vault", "v1", "/secret/store", "--type", "secret", "--secret", "key1", "--name", "API_KEY", "--folder", folder)
+	apiID := r["id"].(string)
+
+	template := filepath.Join(t.TempDir(), ".env.pangea")
+	err := os.WriteFile(template, []byte(fmt.Sprintf(`DB_USER=admin
+DB_PASS=pangea://workspace/%s/DB_PASSWORD#version=1
+DB_PASS_CURRENT=pangea://workspace/%s/DB_PASSWORD
+KEY=pangea://vault/%s
+`, workspace, workspace, apiID)), 0644)
+	assert.NoError(t, err)
+
+	out := runRaw("vault", "workspace", "run", "--env-file", template, "--", "sh", "-c", "echo $DB_USER,$DB_PASS,$DB_PASS_CURRENT,$KEY,${API_KEY:-unset}")
+	assert.Equal(t, "admin,pass1,pass2,key1,unset\n", out)
+
+	// The template on the current directory is only used without a workspace
+	bin, err := filepath.Abs(pangeaCLICommand)
+	assert.NoError(t, err)
+	cmd := exec.Command(bin, "vault", "workspace", "run", "-w", folder, "--", "sh", "-c", "echo ${DB_USER:-unset},$API_KEY")
+	cmd.Dir = filepath.Dir(template)
+	output, err := cmd.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "unset,key1\n", string(output))
+
+	cmd = exec.Command("./"+pangeaCLICommand, "vault", "workspace", "run", "-w", folder, "--env-file", template, "--", "echo", "started")
+	output, err = cmd.CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(output), "only one of `workspace` or `env-file` flags could be set")
+
+	// Unresolved references abort the command before running it
+	err = os.WriteFile(template, []byte(fmt.Sprintf("DB_PASS=pangea://workspace/%s/MISSING\nKEY=pangea://vault/%s#version=5\n", workspace, apiID)), 0644)
+	assert.NoError(t, err)
+	cmd = exec.Command("./"+pangeaCLICommand, "vault", "workspace", "run", "--env-file", template, "--", "echo", "started")
+	output, err = cmd.CombinedOutput()
+	assert.Error(t, err)
+	assert.NotContains(t, string(output), "started")
+	assert.Contains(t, string(output), fmt.Sprintf("DB_PASS: secret MISSING on workspace %s not found", folder))
+	assert.Contains(t, string(output), "KEY: failed to get secret "+apiID+" (version 5)")
+}
+
+// workspaceRun returns a command that runs args with `vault workspace run`, using a template with a
+// plain variable, so no workspace is needed.
+func workspaceRun(t *testing.T, args ...string) *exec.Cmd {
+	template := filepath.Join(t.TempDir(), ".env.pangea")
+	assert.NoError(t, os.WriteFile(template, []byte("GREETING=hello\n"), 0644))
+	return exec.Command("./"+pangeaCLICommand, append([]string{"vault", "workspace", "run", "--env-file", template, "--"}, args...)...)
+}
+
+func TestWorkspaceRunExitCode(t *testing.T) {
+	cmd := workspaceRun(t, "sh", "-c", "echo $GREETING; exit 7")
+	output, err := cmd.Output()
+	assert.Equal(t, "hello\n", string(output))
+	assert.Equal(t, 7, cmd.ProcessState.ExitCode(), err)
+
+	cmd = workspaceRun(t, "cat")
+	cmd.Stdin = strings.NewReader("from stdin")
+	output, err = cmd.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "from stdin", string(output))
+}
+
+func TestWorkspaceRunExec(t *testing.T) {
+	cmd := workspaceRun(t, "sh", "-c", "echo $GREETING; exit 3")
+	cmd.Args = slices.Insert(cmd.Args, 4, "--exec")
+	output, _ := cmd.Output()
+	assert.Equal(t, "hello\n", string(output))
+	assert.Equal(t, 3, cmd.ProcessState.ExitCode())
+}
+
+func TestWorkspaceRunForwardsSignals(t *testing.T) {
+	// The shell exits with 42 when its process group gets SIGTERM
+	cmd := workspaceRun(t, "sh", "-c", `trap 'exit 42' TERM; echo ready; sleep 10 & wait`)
+	stdout, err := cmd.StdoutPipe()
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Start())
+
+	line := make([]byte, len("ready\n"))
+	_, err = stdout.Read(line)
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+
+	start := time.Now()
+	_ = cmd.Wait()
+	assert.Equal(t, 42, cmd.ProcessState.ExitCode())
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestWorkspaceRunWatch(t *testing.T) {
+	workspace := fmt.Sprintf("/watch_%d/", time.Now().UnixMilli())
+	r := run("vault", "v1", "/secret/store", "--type", "secret", "--secret", "v1", "--name", "TOKEN", "--folder", workspace)
+	id := r["id"].(string)
+
+	secretsFile := filepath.Join(t.TempDir(), ".env")
+	cmd := exec.Command("./"+pangeaCLICommand, "vault", "workspace", "run", "-w", workspace, "--watch", "--watch-interval", "200ms", "--debounce", "100ms",
+		"--on-change", "SIGHUP", "--secrets-file", secretsFile, "--",
+		"sh", "-c", `trap '. '"$0"'; echo reloaded $TOKEN' HUP; echo started $TOKEN; while true; do sleep 0.1; done`, secretsFile)
+	stdout, err := cmd.StdoutPipe()
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+
+	lines := bufio.NewScanner(stdout)
+	assert.True(t, lines.Scan())
+	assert.Equal(t, "started v1", lines.Text())
+
+	// The command is signaled after the new secret is written
+	run("vault", "v1", "/secret/rotate", "--id", id, "--secret", "v2")
+	assert.True(t, lines.Scan())
+	assert.Equal(t, "reloaded v2", lines.Text())
+
+	assert.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+	_ = cmd.Wait()
+}
+
+func TestWorkspaceRunWatchRestart(t *testing.T) {
+	workspace := fmt.Sprintf("/watch_%d/", time.Now().UnixMilli())
+	r := run("vault", "v1", "/secret/store", "--type", "secret", "--secret", "v1", "--name", "TOKEN", "--folder", workspace)
+	id := r["id"].(string)
+
+	cmd := exec.Command("./"+pangeaCLICommand, "vault", "workspace", "run", "-w", workspace, "--watch", "--watch-interval", "200ms", "--debounce", "100ms",
+		"--max-restarts", "1", "--", "sh", "-c", "echo started $TOKEN; sleep 10 & wait")
+	stdout, err := cmd.StdoutPipe()
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+
+	lines := bufio.NewScanner(stdout)
+	assert.True(t, lines.Scan())
+	assert.Equal(t, "started v1", lines.Text())
+
+	run("vault", "v1", "/secret/rotate", "--id", id, "--secret", "v2")
+	assert.True(t, lines.Scan())
+	assert.Equal(t, "started v2", lines.Text())
+
+	// Over the max restarts, changes are not applied and the command keeps running
+	run("vault", "v1", "/secret/rotate", "--id", id, "--secret", "v3")
+	time.Sleep(time.Second)
+	assert.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+	assert.False(t, lines.Scan())
+	_ = cmd.Wait()
+	assert.Equal(t, 128+int(syscall.SIGTERM), cmd.ProcessState.ExitCode())
+}
+
+func TestWorkspaceListSecretsPages(t *testing.T) {
+	// More secrets than a list page has
+	workspace := fmt.Sprintf("/pages_%d/", time.Now().UnixMilli())
+	for i := range 55 {
+		run("vault", "v1", "/secret/store", "--type", "secret", "--secret", fmt.Sprintf("value%d", i), "--name", fmt.Sprintf("SECRET_%02d", i), "--folder", workspace)
+	}
+
+	output := runRaw("vault", "workspace", "list-secrets", "-w", workspace, "--show-secrets", "--output", "json-compact", "--query", "items[].name")
+	var names []string
+	assert.NoError(t, json.Unmarshal([]byte(output), &names))
+	assert.Len(t, names, 55)
+	assert.True(t, slices.IsSorted(names))
+
+	out := runRaw("vault", "workspace", "run", "-w", workspace, "--", "sh", "-c", "echo $SECRET_00,$SECRET_54")
+	assert.Equal(t, "value0,value54\n", out)
+
+	// Errors are reported instead of running the command
+	cmd := exec.Command("./"+pangeaCLICommand, "vault", "workspace", "run", "--", "echo", "started")
+	cmd.Env = append(os.Environ(), "PANGEA_DEFAULT_FOLDER=")
+	failed, err := cmd.CombinedOutput()
+	assert.Error(t, err)
+	assert.NotContains(t, string(failed), "started\n")
+	assert.Contains(t, string(failed), "pangea vault workspace select")
+}
+
+func TestWorkspaceRunCache(t *testing.T) {
+	workspace := fmt.Sprintf("/cache_%d/", time.Now().UnixMilli())
+	r := run("vault", "v1", "/secret/store", "--type", "secret", "--secret", "v1", "--name", "TOKEN", "--folder", workspace)
+	id := r["id"].(string)
+
+	// cachedRun runs the command with the secret cache sealed with a passphrase, as there is no OS keyring
+	cachedRun := func(env []string, args ...string) (string, error) {
+		args = append([]string{"vault", "workspace", "run", "-w", workspace}, args...)
+		cmd := exec.Command("./"+pangeaCLICommand, append(args, "--", "sh", "-c", "echo $TOKEN")...)
+		cmd.Env = append(os.Environ(), "PANGEA_CLI_CACHE_PASSPHRASE=test passphrase")
+		cmd.Env = append(cmd.Env, env...)
+		output, err := cmd.Output()
+		return string(output), err
+	}
+
+	out, err := cachedRun(nil, "--cache")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1\n", out)
+
+	// Cached secrets are used while they're fresh, even without reaching Pangea
+	run("vault", "v1", "/secret/rotate", "--id", id, "--secret", "v2")
+	out, err = cachedRun(nil, "--cache")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1\n", out)
+	out, err = cachedRun([]string{"HTTP_PROXY=http://127.0.0.1:1"}, "--offline")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1\n", out)
+
+	out, err = cachedRun(nil, "--refresh")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2\n", out)
+
+	out, err = cachedRun([]string{"PANGEA_CLI_CACHE_PASSPHRASE=wrong"}, "--offline")
+	assert.Error(t, err)
+	assert.Empty(t, out)
+	out, err = cachedRun(nil, "--offline", "--cache-max-age", "1ns")
+	assert.Error(t, err)
+	assert.Empty(t, out)
+}
@@ -0,0 +1,25 @@
+#!/bin/sh
+# Downloads the OpenAPI specs embedded in the CLI binary, on cli/bundle. Services are the arguments, or
+# all the ones supported by the CLI if there are none, as listed by `pangea admin services`.
+# PANGEA_TOKEN is sent if it's set.
+#
+# Fails if any spec could not be downloaded, so releases don't ship without them.
+set -e
+
+DOMAIN="${PANGEA_BUNDLE_DOMAIN:-aws.us.pangea.cloud}"
+FOLDER="$(dirname "$0")/../cli/bundle"
+SERVICES="$*"
+if [ -z "$SERVICES" ]; then
+	SERVICES="$(go run "$(dirname "$0")/../cmd/main.go" admin services -o csv | tail -n +2 | cut -d, -f1)"
+fi
+
+failed=0
+for svc in $SERVICES; do
+	echo "Downloading $svc spec..."
+	if ! curl -sSf ${PANGEA_TOKEN:+-H "Authorization: Bearer $PANGEA_TOKEN"} -o "$FOLDER/$svc.json" "https://$svc.$DOMAIN/v1/openapi.json"; then
+		echo "Failed to download $svc spec" >&2
+		rm -f "$FOLDER/$svc.json"
+		failed=1
+	fi
+done
+exit $failed
@@ -0,0 +1,364 @@
+// Package mockpangea implements an in-memory stand-in for Pangea services, so the CLI integration tests
+// could run without a Pangea project or network access.
+//
+// It serves the OpenAPI specs on `specs` folder and fake implementations of their endpoints. The service
+// is taken from the first label of the request host, as Pangea URLs are `https://<service>.<domain>`.
+// Some requests are queued, like File Intel lookups of queuedHashes: they're answered with `Accepted` status
+// until their result is polled from `/request/<request_id>`.
+// Point the CLI to it with a `http://` domain and the server as HTTP proxy:
+//
+//	PANGEA_DOMAIN=http://pangea.test HTTP_PROXY=<server url> pangea vault v1 /list
+package mockpangea
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token accepted by the server when none is set
+const DefaultToken = "pts_mockpangeatoken0000000000000000"
+
+const openAPIPath = "/v1/openapi.json"
+
+// Endpoint that returns the result of queued requests
+const requestResultPath = "/request/"
+
+//go:embed specs/*.json
+var specs embed.FS
+
+// handlerFunc handles a request to a service endpoint. It returns the result of the response, or an error
+// that is sent as the response status.
+type handlerFunc func(body map[string]any) (any, error)
+
+// accepted is returned by handlers of queued requests, answered with `Accepted` status. Their result is
+// returned by the `/request/<request_id>` endpoint once it's polled `polls` times, or never if it's negative.
+type accepted struct {
+	svc    string
+	result any
+	polls  int
+}
+
+// Error is a failed response. Status is the Pangea response status, like `ValidationError`.
+type Error struct {
+	HTTPStatus int
+	Status     string
+	Summary    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Status, e.Summary)
+}
+
+func validationError(format string, args ...any) *Error {
+	return &Error{HTTPStatus: http.StatusBadRequest, Status: "ValidationError", Summary: fmt.Sprintf(format, args...)}
+}
+
+func notFoundError(id string) *Error {
+	return &Error{HTTPStatus: http.StatusBadRequest, Status: "VaultItemNotFound", Summary: fmt.Sprintf("Item %s not found", id)}
+}
+
+// Server is an http.Handler that fakes Pangea services. It's safe for concurrent use.
+type Server struct {
+	Token string
+
+	started  time.Time
+	mu       sync.Mutex
+	services map[string]map[string]handlerFunc
+	queued   map[string]*accepted
+	vault    *vault
+}
+
+// New returns a server that accepts token, or DefaultToken if it's empty.
+func New(token string) *Server {
+	if token == "" {
+		token = DefaultToken
+	}
+
+	s := &Server{
+		Token:   token,
+		started: time.Now().UTC().Truncate(time.Second),
+		queued:  map[string]*accepted{},
+		vault:   newVault(),
+	}
+	s.services = map[string]map[string]handlerFunc{
+		"vault":      s.vault.handlers(),
+		"audit":      auditHandlers(),
+		"embargo":    embargoHandlers(),
+		"redact":     redactHandlers(),
+		"file-intel": fileIntelHandlers(),
+		"sanitize":   sanitizeHandlers(),
+	}
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Only plain HTTP proxy requests are supported
+	if r.Method == http.MethodConnect {
+		http.Error(w, "CONNECT is not supported", http.StatusForbidden)
+		return
+	}
+
+	svc := serviceFromHost(r.Host)
+	handlers, ok := s.services[svc]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == http.MethodGet && r.URL.Path == openAPIPath {
+		s.serveSpec(w, r, svc)
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeResponse(w, http.StatusUnauthorized, "Unauthorized", "Not authorized to access this resource", nil)
+		return
+	}
+
+	if id, ok := strings.CutPrefix(r.URL.Path, requestResultPath); ok && r.Method == http.MethodGet {
+		s.serveResult(w, r, svc, id)
+		return
+	}
+
+	handler, ok := handlers[r.URL.Path]
+	if !ok || r.Method != http.MethodPost {
+		writeResponse(w, http.StatusNotFound, "NotFound", fmt.Sprintf("%s %s not found", r.Method, r.URL.Path), nil)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	result, err := handler(body)
+	if a, ok := result.(*accepted); ok && err == nil {
+		id := "prq_" + randomHex(16)
+		a.svc = svc
+		s.queued[id] = a
+		s.mu.Unlock()
+		writeAccepted(w, r, id)
+		return
+	}
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeResponse(w, http.StatusOK, "Success", "Success", result)
+}
+
+// serveResult answers a poll of the result of a queued request, with `Accepted` status while it's pending.
+func (s *Server) serveResult(w http.ResponseWriter, r *http.Request, svc, id string) {
+	s.mu.Lock()
+	a, ok := s.queued[id]
+	pending := ok && a.polls != 0
+	if pending && a.polls > 0 {
+		a.polls--
+	}
+	s.mu.Unlock()
+
+	switch {
+	case !ok || a.svc != svc:
+		writeResponse(w, http.StatusNotFound, "NotFound", fmt.Sprintf("Request %s not found", id), nil)
+	case pending:
+		writeAccepted(w, r, id)
+	default:
+		writeEnvelope(w, http.StatusOK, id, "Success", "Success", a.result)
+	}
+}
+
+// readBody decodes the JSON body of a request. On multipart/form-data requests, it's read from the
+// `request` part, and the file on the `upload` part is set on the uploadField of the body.
+func readBody(r *http.Request) (map[string]any, error) {
+	body := map[string]any{}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, validationError("invalid request body: %v", err)
+		}
+		return body, nil
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, validationError("invalid multipart body: %v", err)
+	}
+	var file *uploadedFile
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, validationError("invalid multipart body: %v", err)
+		}
+		switch part.FormName() {
+		case "request":
+			if err := json.NewDecoder(part).Decode(&body); err != nil {
+				return nil, validationError("invalid request part: %v", err)
+			}
+		case "upload":
+			if file, err = readUploadedFile(part); err != nil {
+				return nil, validationError("invalid upload part: %v", err)
+			}
+		}
+	}
+
+	if file != nil {
+		if err := file.verify(body); err != nil {
+			return nil, err
+		}
+		body[uploadField] = file
+	}
+	return body, nil
+}
+
+// serveSpec serves the spec of a service with ETag and Last-Modified headers, answering conditional
+// requests with 304 Not Modified.
+func (s *Server) serveSpec(w http.ResponseWriter, r *http.Request, svc string) {
+	b, err := specs.ReadFile("specs/" + svc + ".json")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	sum := sha256.Sum256(b)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	http.ServeContent(w, r, svc+".json", s.started, bytes.NewReader(b))
+}
+
+// serviceFromHost returns the first label of host, without port.
+func serviceFromHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	svc, _, _ := strings.Cut(host, ".")
+	return svc
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{HTTPStatus: http.StatusInternalServerError, Status: "InternalError", Summary: err.Error()}
+	}
+	writeResponse(w, e.HTTPStatus, e.Status, e.Summary, nil)
+}
+
+func writeResponse(w http.ResponseWriter, code int, status, summary string, result any) {
+	writeEnvelope(w, code, "prq_"+randomHex(16), status, summary, result)
+}
+
+// writeAccepted answers that the request with id is still in progress.
+func writeAccepted(w http.ResponseWriter, r *http.Request, id string) {
+	writeEnvelope(w, http.StatusAccepted, id, "Accepted", "Your request is in progress. Use 'result' field to get more information.", map[string]any{
+		"location": "https://" + r.Host + requestResultPath + id,
+		"ttl_mins": 5760,
+	})
+}
+
+func writeEnvelope(w http.ResponseWriter, code int, id, status, summary string, result any) {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	env := map[string]any{
+		"request_id":    id,
+		"request_time":  now,
+		"response_time": now,
+		"status":        status,
+		"summary":       summary,
+		"result":        result,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(env)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Helpers to read request body fields
+
+func stringField(body map[string]any, name string) string {
+	s, _ := body[name].(string)
+	return s
+}
+
+func requiredString(body map[string]any, name string) (string, error) {
+	s := stringField(body, name)
+	if s == "" {
+		return "", validationError("'%s' is required", name)
+	}
+	return s, nil
+}
+
+// Field of the request body with the *uploadedFile of multipart requests
+const uploadField = "_upload"
+
+// uploadedFile is a file sent on the `upload` part of a multipart request.
+type uploadedFile struct {
+	Name    string
+	Content []byte
+	SHA256  string
+	CRC32C  string
+}
+
+func readUploadedFile(part *multipart.Part) (*uploadedFile, error) {
+	b, err := io.ReadAll(part)
+	if err != nil {
+		return nil, err
+	}
+	sha := sha256.Sum256(b)
+	crc := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	crc.Write(b)
+	return &uploadedFile{
+		Name:    part.FileName(),
+		Content: b,
+		SHA256:  hex.EncodeToString(sha[:]),
+		CRC32C:  hex.EncodeToString(crc.Sum(nil)),
+	}, nil
+}
+
+// verify checks the file against the size and hashes set on the request body, as Pangea does.
+func (f *uploadedFile) verify(body map[string]any) error {
+	if size, ok := body["size"].(float64); ok && int(size) != len(f.Content) {
+		return validationError("file size %d does not match 'size' %d", len(f.Content), int(size))
+	}
+	if sha := stringField(body, "sha256"); sha != "" && sha != f.SHA256 {
+		return validationError("file SHA256 does not match 'sha256'")
+	}
+	if crc := stringField(body, "crc32c"); crc != "" && crc != f.CRC32C {
+		return validationError("file CRC32C does not match 'crc32c'")
+	}
+	return nil
+}
+
+func stringList(body map[string]any, name string) []string {
+	list, _ := body[name].([]any)
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
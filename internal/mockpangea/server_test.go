@@ -0,0 +1,195 @@
+package mockpangea_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/pangeacyber/pangea-cli/v2/internal/mockpangea"
+	"github.com/stretchr/testify/assert"
+)
+
+// post sends body to path of svc through the mock server, as the CLI does when it's set as HTTP proxy.
+func post(t *testing.T, server *httptest.Server, token, svc, path string, body any) (int, map[string]any) {
+	t.Helper()
+
+	b, err := json.Marshal(body)
+	assert.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, server.URL+path, bytes.NewReader(b))
+	assert.NoError(t, err)
+	req.Host = svc + ".pangea.test"
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := server.Client().Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	env := map[string]any{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&env))
+	return resp.StatusCode, env
+}
+
+func TestSpecs(t *testing.T) {
+	server := httptest.NewServer(mockpangea.New(""))
+	defer server.Close()
+
+	for _, svc := range []string{"vault", "audit", "embargo", "redact", "file-intel", "sanitize"} {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/openapi.json", nil)
+		assert.NoError(t, err)
+		req.Host = svc + ".pangea.test"
+
+		resp, err := server.Client().Do(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		oapi, err := cli.LoadReader(resp.Body, "http://"+svc+".pangea.test/v1/openapi.json")
+		resp.Body.Close()
+		assert.NoError(t, err, svc)
+		assert.NotEmpty(t, oapi.Paths, svc)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	server := httptest.NewServer(mockpangea.New(""))
+	defer server.Close()
+
+	code, env := post(t, server, "pts_wrongtoken", "embargo", "/v1/iso/check", map[string]any{"iso_code": "CU"})
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, "Unauthorized", env["status"])
+}
+
+func TestVaultSignVerify(t *testing.T) {
+	server := httptest.NewServer(mockpangea.New(""))
+	defer server.Close()
+	token := mockpangea.DefaultToken
+
+	code, env := post(t, server, token, "vault", "/v1/key/generate", map[string]any{
+		"type":      "asymmetric_key",
+		"purpose":   "signing",
+		"algorithm": "ED25519",
+	})
+	assert.Equal(t, http.StatusOK, code)
+	id := env["result"].(map[string]any)["id"]
+	assert.NotEmpty(t, id)
+
+	_, env = post(t, server, token, "vault", "/v1/key/sign", map[string]any{"id": id, "message": "aGVsbG8="})
+	assert.Equal(t, "Success", env["status"])
+	signature := env["result"].(map[string]any)["signature"]
+
+	_, env = post(t, server, token, "vault", "/v1/key/verify", map[string]any{"id": id, "message": "aGVsbG8=", "signature": signature})
+	assert.Equal(t, true, env["result"].(map[string]any)["valid_signature"])
+
+	_, env = post(t, server, token, "vault", "/v1/key/verify", map[string]any{"id": id, "message": "Ynll", "signature": signature})
+	assert.Equal(t, false, env["result"].(map[string]any)["valid_signature"])
+
+	code, env = post(t, server, token, "vault", "/v1/get", map[string]any{"id": "pvi_doesnotexist"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "VaultItemNotFound", env["status"])
+}
+
+func TestVaultGetVersions(t *testing.T) {
+	server := httptest.NewServer(mockpangea.New(""))
+	defer server.Close()
+	token := mockpangea.DefaultToken
+
+	_, env := post(t, server, token, "vault", "/v1/secret/store", map[string]any{"type": "secret", "secret": "v1"})
+	id := env["result"].(map[string]any)["id"]
+	post(t, server, token, "vault", "/v1/secret/rotate", map[string]any{"id": id, "secret": "v2"})
+	post(t, server, token, "vault", "/v1/secret/rotate", map[string]any{"id": id, "secret": "v3"})
+
+	secrets := func(version string) []any {
+		_, env := post(t, server, token, "vault", "/v1/get", map[string]any{"id": id, "version": version})
+		result := env["result"].(map[string]any)
+		assert.Equal(t, "v3", result["current_version"].(map[string]any)["secret"])
+		values := []any{}
+		for _, v := range result["versions"].([]any) {
+			values = append(values, v.(map[string]any)["secret"])
+		}
+		return values
+	}
+	assert.Equal(t, []any{"v1"}, secrets("1"))
+	assert.Equal(t, []any{"v2", "v3"}, secrets("-2"))
+	assert.Equal(t, []any{"v1", "v2", "v3"}, secrets("all"))
+}
+
+func TestQueuedRequest(t *testing.T) {
+	server := httptest.NewServer(mockpangea.New(""))
+	defer server.Close()
+
+	poll := func(id string) (int, map[string]any) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/request/"+id, nil)
+		assert.NoError(t, err)
+		req.Host = "file-intel.pangea.test"
+		req.Header.Set("Authorization", "Bearer "+mockpangea.DefaultToken)
+
+		resp, err := server.Client().Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		env := map[string]any{}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&env))
+		return resp.StatusCode, env
+	}
+
+	// Ready after being polled once
+	code, env := post(t, server, mockpangea.DefaultToken, "file-intel", "/v1/reputation", map[string]any{
+		"hash":      "1de7f7e7a4cd3a0b1e0ed6e2a5f0b1d1e1c2f6f0d1b8e2f3a4c5d6e7f8091a2b",
+		"hash_type": "sha256",
+	})
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.Equal(t, "Accepted", env["status"])
+	id := env["request_id"].(string)
+
+	code, env = poll(id)
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.Equal(t, id, env["request_id"])
+	code, env = poll(id)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, id, env["request_id"])
+	assert.Equal(t, "unknown", env["result"].(map[string]any)["data"].(map[string]any)["verdict"])
+
+	code, env = poll("prq_doesnotexist")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, "NotFound", env["status"])
+}
+
+func TestSanitizeMultipart(t *testing.T) {
+	server := httptest.NewServer(mockpangea.New(""))
+	defer server.Close()
+
+	send := func(request string) (int, map[string]any) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		assert.NoError(t, mw.WriteField("request", request))
+		fw, err := mw.CreateFormFile("upload", "file.txt")
+		assert.NoError(t, err)
+		_, _ = fw.Write([]byte("hello"))
+		assert.NoError(t, mw.Close())
+
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/sanitize", &buf)
+		assert.NoError(t, err)
+		req.Host = "sanitize.pangea.test"
+		req.Header.Set("Authorization", "Bearer "+mockpangea.DefaultToken)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		resp, err := server.Client().Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		env := map[string]any{}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&env))
+		return resp.StatusCode, env
+	}
+
+	code, env := send(`{"transfer_method": "multipart", "size": 5}`)
+	assert.Equal(t, http.StatusOK, code)
+	params := env["result"].(map[string]any)["parameters"].(map[string]any)
+	assert.Equal(t, "file.txt", params["file_name"])
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", params["sha256"])
+
+	code, env = send(`{"transfer_method": "multipart", "size": 6}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "ValidationError", env["status"])
+}
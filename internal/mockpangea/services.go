@@ -0,0 +1,301 @@
+package mockpangea
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Sanctions returned by the fake Embargo service, by country ISO code
+var sanctions = map[string][]map[string]any{
+	"CU": {sanction("CU", "Cuba", "ITAR")},
+	"IR": {sanction("IR", "Iran", "ITAR")},
+	"KP": {sanction("KP", "North Korea", "ITAR")},
+	"RU": {sanction("RU", "Russia", "ITAR")},
+	"SY": {sanction("SY", "Syria", "ITAR")},
+}
+
+// Countries of the IP addresses known by the fake Embargo service
+var ipCountries = map[string]string{
+	"213.24.238.26": "RU",
+	"190.6.64.94":   "CU",
+}
+
+func sanction(iso, country, list string) map[string]any {
+	return map[string]any{
+		"list_name":                  list,
+		"embargoed_country_name":     country,
+		"embargoed_country_iso_code": iso,
+		"issuing_country":            "US",
+		"annotations": map[string]any{
+			"reference": map[string]any{"paragraph": "d1", "regulation": "CFR 126.1"},
+		},
+	}
+}
+
+func embargoHandlers() map[string]handlerFunc {
+	return map[string]handlerFunc{
+		"/v1/ip/check": func(body map[string]any) (any, error) {
+			ip, err := requiredString(body, "ip")
+			if err != nil {
+				return nil, err
+			}
+			return embargoResult(ipCountries[ip]), nil
+		},
+		"/v1/iso/check": func(body map[string]any) (any, error) {
+			iso, err := requiredString(body, "iso_code")
+			if err != nil {
+				return nil, err
+			}
+			return embargoResult(strings.ToUpper(iso)), nil
+		},
+	}
+}
+
+func embargoResult(iso string) map[string]any {
+	list := sanctions[iso]
+	if list == nil {
+		list = []map[string]any{}
+	}
+	return map[string]any{
+		"count":     len(list),
+		"sanctions": list,
+	}
+}
+
+// Rules of the fake Redact service
+var redactRules = []struct {
+	name   string
+	regexp *regexp.Regexp
+}{
+	{"PHONE_NUMBER", regexp.MustCompile(`\b\d{3}-\d{3}-\d{4}\b`)},
+	{"EMAIL_ADDRESS", regexp.MustCompile(`\b[\w.+-]+@[\w-]+\.[\w.]+\b`)},
+	{"US_SSN", regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+}
+
+func redactHandlers() map[string]handlerFunc {
+	return map[string]handlerFunc{
+		"/v1/redact": func(body map[string]any) (any, error) {
+			text, err := requiredString(body, "text")
+			if err != nil {
+				return nil, err
+			}
+			redacted, count := redactText(text)
+			return map[string]any{
+				"redacted_text": redacted,
+				"count":         count,
+			}, nil
+		},
+		"/v1/redact_structured": func(body map[string]any) (any, error) {
+			data, ok := body["data"]
+			if !ok {
+				return nil, validationError("'data' is required")
+			}
+			redacted, count := redactValue(data)
+			return map[string]any{
+				"redacted_data": redacted,
+				"count":         count,
+			}, nil
+		},
+	}
+}
+
+func redactText(text string) (string, int) {
+	count := 0
+	for _, r := range redactRules {
+		text = r.regexp.ReplaceAllStringFunc(text, func(string) string {
+			count++
+			return "<" + r.name + ">"
+		})
+	}
+	return text, count
+}
+
+func redactValue(v any) (any, int) {
+	switch val := v.(type) {
+	case string:
+		return redactText(val)
+	case map[string]any:
+		count := 0
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			var n int
+			out[k], n = redactValue(item)
+			count += n
+		}
+		return out, count
+	case []any:
+		count := 0
+		out := make([]any, 0, len(val))
+		for _, item := range val {
+			r, n := redactValue(item)
+			out = append(out, r)
+			count += n
+		}
+		return out, count
+	default:
+		return v, 0
+	}
+}
+
+// Hashes known as malicious by the fake File Intel service
+var maliciousHashes = map[string]bool{
+	"142b638c6a60b60c7f9928da4fb85a5a8e1422a9ffdc9ee49e17e56ccca9cf6e": true,
+	"178e2b8a4162372cd9344b81793cbf74a9513a002eda3324e6331243f3137a63": true,
+}
+
+// Hashes whose reputation lookups are queued by the fake File Intel service, by the number of times their
+// result is polled before it's ready. Negative ones are never ready.
+var queuedHashes = map[string]int{
+	"1de7f7e7a4cd3a0b1e0ed6e2a5f0b1d1e1c2f6f0d1b8e2f3a4c5d6e7f8091a2b": 1,
+	"9b3c1a7f2e5d4c6b8a0f1e2d3c4b5a69788796a5b4c3d2e1f0a9b8c7d6e5f4a3": -1,
+}
+
+func fileIntelHandlers() map[string]handlerFunc {
+	return map[string]handlerFunc{
+		"/v2/reputation": func(body map[string]any) (any, error) {
+			hashes := stringList(body, "hashes")
+			if len(hashes) == 0 {
+				return nil, validationError("'hashes' is required")
+			}
+			if _, err := requiredString(body, "hash_type"); err != nil {
+				return nil, err
+			}
+
+			data := map[string]any{}
+			for _, h := range hashes {
+				data[h] = hashReputation(h)
+			}
+			return withParameters(body, map[string]any{"data": data}), nil
+		},
+		"/v1/reputation": func(body map[string]any) (any, error) {
+			hash, err := requiredString(body, "hash")
+			if err != nil {
+				return nil, err
+			}
+			result := withParameters(body, map[string]any{"data": hashReputation(hash)})
+			if polls, ok := queuedHashes[strings.ToLower(hash)]; ok {
+				return &accepted{result: result, polls: polls}, nil
+			}
+			return result, nil
+		},
+	}
+}
+
+// withParameters echoes the request parameters on the result when `verbose` is set, as File Intel does.
+func withParameters(body, result map[string]any) map[string]any {
+	if verbose, _ := body["verbose"].(bool); verbose {
+		result["parameters"] = body
+	}
+	return result
+}
+
+func hashReputation(hash string) map[string]any {
+	if maliciousHashes[strings.ToLower(hash)] {
+		return map[string]any{
+			"category": []string{"Trojan"},
+			"score":    100,
+			"verdict":  "malicious",
+		}
+	}
+	return map[string]any{
+		"category": []string{},
+		"score":    0,
+		"verdict":  "unknown",
+	}
+}
+
+// Events logged on the fake Audit service, oldest first
+var auditEvents = []map[string]any{
+	auditEvent("2026-01-05T09:12:00Z", "alice", "login", "success", "User alice logged in"),
+	auditEvent("2026-01-05T09:30:00Z", "bob", "login", "failure", "User bob failed to log in"),
+	auditEvent("2026-01-05T10:02:00Z", "alice", "delete", "success", "User alice deleted a report"),
+}
+
+func auditEvent(receivedAt, actor, action, status, message string) map[string]any {
+	return map[string]any{
+		"envelope": map[string]any{
+			"event": map[string]any{
+				"actor":   actor,
+				"action":  action,
+				"status":  status,
+				"message": message,
+			},
+			"received_at": receivedAt,
+		},
+		"hash": randomHex(32),
+	}
+}
+
+func auditHandlers() map[string]handlerFunc {
+	return map[string]handlerFunc{
+		"/v1/search": func(body map[string]any) (any, error) {
+			query, err := requiredString(body, "query")
+			if err != nil {
+				return nil, err
+			}
+
+			events := []map[string]any{}
+			for _, e := range auditEvents {
+				if matchesAuditQuery(e["envelope"].(map[string]any)["event"].(map[string]any), query) {
+					events = append(events, e)
+				}
+			}
+			if stringField(body, "order") == "desc" {
+				slices.Reverse(events)
+			}
+			if limit, ok := body["limit"].(float64); ok && int(limit) < len(events) {
+				events = events[:int(limit)]
+			}
+			return map[string]any{
+				"id":     "pas_" + randomHex(16),
+				"count":  len(events),
+				"events": events,
+			}, nil
+		},
+	}
+}
+
+// matchesAuditQuery returns whether an event has all the values of a query. Values prefixed with a field
+// name, like `actor:alice`, match that field, and the rest are looked for on the message.
+func matchesAuditQuery(event map[string]any, query string) bool {
+	for _, term := range strings.Fields(query) {
+		field, value, ok := strings.Cut(term, ":")
+		if !ok {
+			field, value = "message", term
+		}
+		v, _ := event[field].(string)
+		if (ok && v != value) || (!ok && !strings.Contains(v, value)) {
+			return false
+		}
+	}
+	return true
+}
+
+func sanitizeHandlers() map[string]handlerFunc {
+	return map[string]handlerFunc{
+		"/v1/sanitize": func(body map[string]any) (any, error) {
+			method, err := requiredString(body, "transfer_method")
+			if err != nil {
+				return nil, err
+			}
+			if method != "multipart" {
+				return nil, validationError("transfer method '%s' is not supported", method)
+			}
+			file, ok := body[uploadField].(*uploadedFile)
+			if !ok {
+				return nil, validationError("'upload' part is required")
+			}
+			return map[string]any{
+				"data": map[string]any{
+					"malicious_file": maliciousHashes[file.SHA256],
+				},
+				"parameters": map[string]any{
+					"file_name": file.Name,
+					"size":      len(file.Content),
+					"sha256":    file.SHA256,
+				},
+			}, nil
+		},
+	}
+}
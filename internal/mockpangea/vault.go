@@ -0,0 +1,647 @@
+package mockpangea
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default and max page size of list requests
+const (
+	defaultListSize = 50
+	maxListSize     = 1000
+)
+
+// Alphabets supported by format preserving encryption
+var alphabets = map[string]string{
+	"numeric":           "0123456789",
+	"alphalower":        "abcdefghijklmnopqrstuvwxyz",
+	"alphaupper":        "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"alphanumericlower": "0123456789abcdefghijklmnopqrstuvwxyz",
+	"alphanumericupper": "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"alphanumeric":      "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+}
+
+type vaultVersion struct {
+	Version    int
+	State      string
+	CreatedAt  string
+	Secret     string
+	PublicKey  string
+	PrivateKey string
+
+	// Key material of the fake cryptographic operations
+	material []byte
+}
+
+func (v *vaultVersion) data(withSecret bool) map[string]any {
+	d := map[string]any{
+		"version":    v.Version,
+		"state":      v.State,
+		"created_at": v.CreatedAt,
+	}
+	if v.PublicKey != "" {
+		d["public_key"] = v.PublicKey
+	}
+	if withSecret && v.Secret != "" {
+		d["secret"] = v.Secret
+	}
+	return d
+}
+
+type vaultItem struct {
+	ID        string
+	Type      string
+	Name      string
+	Folder    string
+	Purpose   string
+	Algorithm string
+	Tags      []string
+	Metadata  map[string]any
+	CreatedAt string
+	Versions  []*vaultVersion
+}
+
+// current returns the last version of the item.
+func (it *vaultItem) current() *vaultVersion {
+	return it.Versions[len(it.Versions)-1]
+}
+
+// version returns the version number n of the item, or the current one if n is 0.
+func (it *vaultItem) version(n int) (*vaultVersion, error) {
+	if n == 0 {
+		return it.current(), nil
+	}
+	if n < 0 || n > len(it.Versions) {
+		return nil, validationError("version %d of %s not found", n, it.ID)
+	}
+	return it.Versions[n-1], nil
+}
+
+func (it *vaultItem) data(withSecret bool) map[string]any {
+	d := map[string]any{
+		"id":         it.ID,
+		"type":       it.Type,
+		"item_state": "enabled",
+		"name":       it.Name,
+		"folder":     it.Folder,
+		"tags":       it.Tags,
+		"metadata":   it.Metadata,
+		"created_at": it.CreatedAt,
+	}
+	if it.Purpose != "" {
+		d["purpose"] = it.Purpose
+	}
+	if it.Algorithm != "" {
+		d["algorithm"] = it.Algorithm
+	}
+	if len(it.Versions) > 0 {
+		d["current_version"] = it.current().data(withSecret)
+	}
+	return d
+}
+
+// storeResult is the result of requests that create a new item version.
+func (it *vaultItem) storeResult() map[string]any {
+	v := it.current()
+	r := map[string]any{
+		"id":      it.ID,
+		"type":    it.Type,
+		"version": v.Version,
+	}
+	if it.Purpose != "" {
+		r["purpose"] = it.Purpose
+		r["algorithm"] = it.Algorithm
+	}
+	if v.PublicKey != "" {
+		r["public_key"] = v.PublicKey
+	}
+	if v.Secret != "" {
+		r["secret"] = v.Secret
+	}
+	return r
+}
+
+// vault keeps the items of the fake Vault service in memory. Callers should hold the server lock.
+type vault struct {
+	items map[string]*vaultItem
+	order []string
+}
+
+func newVault() *vault {
+	return &vault{items: map[string]*vaultItem{}}
+}
+
+func (v *vault) handlers() map[string]handlerFunc {
+	return map[string]handlerFunc{
+		"/v1/key/generate":          v.keyGenerate,
+		"/v1/key/store":             v.keyStore,
+		"/v1/key/rotate":            v.rotate,
+		"/v1/key/encrypt":           v.keyEncrypt,
+		"/v1/key/decrypt":           v.keyDecrypt,
+		"/v1/key/sign":              v.keySign,
+		"/v1/key/verify":            v.keyVerify,
+		"/v1/key/encrypt/transform": v.keyEncryptTransform,
+		"/v1/key/decrypt/transform": v.keyDecryptTransform,
+		"/v1/secret/store":          v.secretStore,
+		"/v1/secret/rotate":         v.rotate,
+		"/v1/folder/create":         v.folderCreate,
+		"/v1/get":                   v.get,
+		"/v1/list":                  v.list,
+		"/v1/delete":                v.delete,
+	}
+}
+
+func (v *vault) add(body map[string]any, itemType string) *vaultItem {
+	metadata, _ := body["metadata"].(map[string]any)
+	it := &vaultItem{
+		ID:        "pvi_" + randomHex(16),
+		Type:      itemType,
+		Name:      stringField(body, "name"),
+		Folder:    normalizeFolder(stringField(body, "folder")),
+		Purpose:   stringField(body, "purpose"),
+		Algorithm: stringField(body, "algorithm"),
+		Tags:      stringList(body, "tags"),
+		Metadata:  metadata,
+		CreatedAt: now(),
+	}
+	if it.Name == "" {
+		it.Name = it.ID
+	}
+	v.items[it.ID] = it
+	v.order = append(v.order, it.ID)
+	return it
+}
+
+func (v *vault) addVersion(it *vaultItem, body map[string]any) *vaultVersion {
+	material := make([]byte, 32)
+	_, _ = rand.Read(material)
+
+	ver := &vaultVersion{
+		Version:    len(it.Versions) + 1,
+		State:      "active",
+		CreatedAt:  now(),
+		Secret:     stringField(body, "secret"),
+		PublicKey:  stringField(body, "public_key"),
+		PrivateKey: stringField(body, "private_key"),
+		material:   material,
+	}
+	if key := stringField(body, "key"); key != "" {
+		ver.material = []byte(key)
+	}
+	if ver.PrivateKey != "" {
+		ver.material = []byte(ver.PrivateKey)
+	}
+	if it.Type == "asymmetric_key" && ver.PublicKey == "" {
+		ver.PublicKey = fmt.Sprintf("-----BEGIN PUBLIC KEY-----\n%s\n-----END PUBLIC KEY-----\n", base64.StdEncoding.EncodeToString(material))
+	}
+
+	it.Versions = append(it.Versions, ver)
+	return ver
+}
+
+func (v *vault) item(body map[string]any, types ...string) (*vaultItem, error) {
+	id, err := requiredString(body, "id")
+	if err != nil {
+		return nil, err
+	}
+	it, ok := v.items[id]
+	if !ok {
+		return nil, notFoundError(id)
+	}
+	if len(types) > 0 && !slices.Contains(types, it.Type) {
+		return nil, validationError("item %s is a %s", id, it.Type)
+	}
+	return it, nil
+}
+
+func (v *vault) keyGenerate(body map[string]any) (any, error) {
+	t, err := keyType(body)
+	if err != nil {
+		return nil, err
+	}
+	it := v.add(body, t)
+	v.addVersion(it, map[string]any{})
+	return it.storeResult(), nil
+}
+
+func (v *vault) keyStore(body map[string]any) (any, error) {
+	t, err := keyType(body)
+	if err != nil {
+		return nil, err
+	}
+	if t == "asymmetric_key" && (stringField(body, "public_key") == "" || stringField(body, "private_key") == "") {
+		return nil, validationError("'public_key' and 'private_key' are required")
+	}
+	if t == "symmetric_key" && stringField(body, "key") == "" {
+		return nil, validationError("'key' is required")
+	}
+
+	it := v.add(body, t)
+	v.addVersion(it, body)
+	return it.storeResult(), nil
+}
+
+func (v *vault) secretStore(body map[string]any) (any, error) {
+	t := stringField(body, "type")
+	if t != "secret" && t != "pangea_token" {
+		return nil, validationError("invalid type '%s'", t)
+	}
+	if _, err := requiredString(body, "secret"); err != nil {
+		return nil, err
+	}
+
+	it := v.add(body, t)
+	v.addVersion(it, body)
+	return it.storeResult(), nil
+}
+
+// rotate adds a new version to a key or secret. The previous one is set to `rotation_state`.
+func (v *vault) rotate(body map[string]any) (any, error) {
+	it, err := v.item(body, "asymmetric_key", "symmetric_key", "secret", "pangea_token")
+	if err != nil {
+		return nil, err
+	}
+	if (it.Type == "secret" || it.Type == "pangea_token") && stringField(body, "secret") == "" {
+		return nil, validationError("'secret' is required")
+	}
+
+	state := stringField(body, "rotation_state")
+	if state == "" {
+		state = "deactivated"
+	}
+	it.current().State = state
+	v.addVersion(it, body)
+	return it.storeResult(), nil
+}
+
+func (v *vault) folderCreate(body map[string]any) (any, error) {
+	name, err := requiredString(body, "name")
+	if err != nil {
+		return nil, err
+	}
+
+	folder := normalizeFolder(stringField(body, "folder"))
+	for _, it := range v.items {
+		if it.Type == "folder" && it.Name == name && it.Folder == folder {
+			return nil, validationError("folder %s already exists", strings.TrimSuffix(folder, "/")+"/"+name)
+		}
+	}
+
+	it := v.add(body, "folder")
+	return map[string]any{
+		"id":         it.ID,
+		"type":       it.Type,
+		"name":       it.Name,
+		"folder":     it.Folder,
+		"created_at": it.CreatedAt,
+	}, nil
+}
+
+func (v *vault) get(body map[string]any) (any, error) {
+	it, err := v.item(body)
+	if err != nil {
+		return nil, err
+	}
+
+	d := it.data(true)
+	if version, ok := body["version"]; ok {
+		versions, err := it.requestedVersions(version)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]map[string]any, 0, len(versions))
+		for _, ver := range versions {
+			list = append(list, ver.data(true))
+		}
+		d["versions"] = list
+	}
+	return d, nil
+}
+
+// requestedVersions returns the versions of `version` field of `/v1/get`: `all` of them, `num` for a
+// specific one, or `-num` for the num latest ones. The current version is always on `current_version`.
+func (it *vaultItem) requestedVersions(version any) ([]*vaultVersion, error) {
+	var n int
+	switch version := version.(type) {
+	case float64:
+		n = int(version)
+	case string:
+		if version == "all" {
+			return it.Versions, nil
+		}
+		var err error
+		if n, err = strconv.Atoi(version); err != nil {
+			return nil, validationError("invalid version '%s'", version)
+		}
+	default:
+		return nil, validationError("invalid version '%v'", version)
+	}
+
+	if n < 0 {
+		return it.Versions[max(len(it.Versions)+n, 0):], nil
+	}
+	ver, err := it.version(n)
+	if err != nil {
+		return nil, err
+	}
+	return []*vaultVersion{ver}, nil
+}
+
+// list returns the items matching `filter`, a page of `size` items at a time. `last` is the cursor of the
+// next page, an opaque string for clients.
+func (v *vault) list(body map[string]any) (any, error) {
+	filter, _ := body["filter"].(map[string]any)
+	includeSecrets, _ := body["include_secrets"].(bool)
+
+	items := []*vaultItem{}
+	for _, id := range v.order {
+		it, ok := v.items[id]
+		if ok && matchesFilter(it, filter) {
+			items = append(items, it)
+		}
+	}
+
+	orderBy := stringField(body, "order_by")
+	if orderBy != "" {
+		sort.SliceStable(items, func(i, j int) bool {
+			return fmt.Sprint(items[i].data(false)[orderBy]) < fmt.Sprint(items[j].data(false)[orderBy])
+		})
+	}
+	if stringField(body, "order") == "desc" {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	size := defaultListSize
+	if s, ok := body["size"].(float64); ok && s > 0 {
+		size = min(int(s), maxListSize)
+	}
+	offset := 0
+	if last := stringField(body, "last"); last != "" {
+		b, err := base64.RawURLEncoding.DecodeString(last)
+		if err == nil {
+			offset, err = strconv.Atoi(string(b))
+		}
+		if err != nil || offset < 0 || offset > len(items) {
+			return nil, validationError("invalid 'last' value")
+		}
+	}
+
+	end := min(offset+size, len(items))
+	page := make([]map[string]any, 0, end-offset)
+	for _, it := range items[offset:end] {
+		page = append(page, it.data(includeSecrets))
+	}
+
+	result := map[string]any{
+		"items": page,
+		"count": len(page),
+	}
+	if end < len(items) {
+		result["last"] = base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(end)))
+	}
+	return result, nil
+}
+
+func (v *vault) delete(body map[string]any) (any, error) {
+	it, err := v.item(body)
+	if err != nil {
+		return nil, err
+	}
+	delete(v.items, it.ID)
+	return map[string]any{"id": it.ID}, nil
+}
+
+func (v *vault) keyEncrypt(body map[string]any) (any, error) {
+	it, err := v.item(body, "symmetric_key")
+	if err != nil {
+		return nil, err
+	}
+	plain, err := requiredString(body, "plain_text")
+	if err != nil {
+		return nil, err
+	}
+
+	ver := it.current()
+	cipher := xorStream(ver.material, []byte(plain))
+	return map[string]any{
+		"id":          it.ID,
+		"version":     ver.Version,
+		"algorithm":   it.Algorithm,
+		"cipher_text": base64.StdEncoding.EncodeToString(append([]byte{byte(ver.Version)}, cipher...)),
+	}, nil
+}
+
+func (v *vault) keyDecrypt(body map[string]any) (any, error) {
+	it, err := v.item(body, "symmetric_key")
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := requiredString(body, "cipher_text")
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := base64.StdEncoding.DecodeString(cipherText)
+	if err != nil || len(b) == 0 {
+		return nil, validationError("invalid 'cipher_text'")
+	}
+	ver, err := it.version(int(b[0]))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"id":         it.ID,
+		"version":    ver.Version,
+		"algorithm":  it.Algorithm,
+		"plain_text": string(xorStream(ver.material, b[1:])),
+	}, nil
+}
+
+func (v *vault) keySign(body map[string]any) (any, error) {
+	it, err := v.item(body, "asymmetric_key")
+	if err != nil {
+		return nil, err
+	}
+	message, err := requiredString(body, "message")
+	if err != nil {
+		return nil, err
+	}
+
+	ver := it.current()
+	return map[string]any{
+		"id":         it.ID,
+		"version":    ver.Version,
+		"algorithm":  it.Algorithm,
+		"public_key": ver.PublicKey,
+		"signature":  base64.StdEncoding.EncodeToString(sign(ver.material, message)),
+	}, nil
+}
+
+func (v *vault) keyVerify(body map[string]any) (any, error) {
+	it, err := v.item(body, "asymmetric_key")
+	if err != nil {
+		return nil, err
+	}
+	message, err := requiredString(body, "message")
+	if err != nil {
+		return nil, err
+	}
+	signature, err := requiredString(body, "signature")
+	if err != nil {
+		return nil, err
+	}
+
+	sig, _ := base64.StdEncoding.DecodeString(signature)
+	valid := false
+	version := it.current().Version
+	for _, ver := range it.Versions {
+		if hmac.Equal(sig, sign(ver.material, message)) {
+			valid = true
+			version = ver.Version
+			break
+		}
+	}
+	return map[string]any{
+		"id":              it.ID,
+		"version":         version,
+		"algorithm":       it.Algorithm,
+		"valid_signature": valid,
+	}, nil
+}
+
+func (v *vault) keyEncryptTransform(body map[string]any) (any, error) {
+	return v.transform(body, "plain_text", "cipher_text", 1)
+}
+
+func (v *vault) keyDecryptTransform(body map[string]any) (any, error) {
+	return v.transform(body, "cipher_text", "plain_text", -1)
+}
+
+// transform fakes format preserving encryption, shifting each character of the alphabet by an offset
+// derived from the key and tweak. Characters out of the alphabet are kept.
+func (v *vault) transform(body map[string]any, in, out string, direction int) (any, error) {
+	it, err := v.item(body, "symmetric_key")
+	if err != nil {
+		return nil, err
+	}
+	text, err := requiredString(body, in)
+	if err != nil {
+		return nil, err
+	}
+	alphabet, ok := alphabets[stringField(body, "alphabet")]
+	if !ok {
+		return nil, validationError("invalid alphabet '%s'", stringField(body, "alphabet"))
+	}
+
+	tweak := stringField(body, "tweak")
+	if tweak == "" {
+		if direction < 0 {
+			return nil, validationError("'tweak' is required")
+		}
+		b := make([]byte, 7)
+		_, _ = rand.Read(b)
+		tweak = base64.StdEncoding.EncodeToString(b)
+	}
+
+	ver := it.current()
+	offsets := sign(ver.material, tweak)
+	result := []rune{}
+	for i, r := range []rune(text) {
+		ndx := strings.IndexRune(alphabet, r)
+		if ndx < 0 {
+			result = append(result, r)
+			continue
+		}
+		n := len(alphabet)
+		shift := int(offsets[i%len(offsets)]) % n
+		result = append(result, rune(alphabet[((ndx+direction*shift)%n+n)%n]))
+	}
+
+	return map[string]any{
+		"id":       it.ID,
+		"version":  ver.Version,
+		"tweak":    tweak,
+		"alphabet": stringField(body, "alphabet"),
+		out:        string(result),
+	}, nil
+}
+
+func keyType(body map[string]any) (string, error) {
+	t := stringField(body, "type")
+	if t != "asymmetric_key" && t != "symmetric_key" {
+		return "", validationError("invalid key type '%s'", t)
+	}
+	if _, err := requiredString(body, "purpose"); err != nil {
+		return "", err
+	}
+	if _, err := requiredString(body, "algorithm"); err != nil {
+		return "", err
+	}
+	return t, nil
+}
+
+// matchesFilter checks the fields of an item against a list filter. Keys ending with `__contains` match
+// substrings, the rest of them exact values. Tags match if the item has the tag.
+func matchesFilter(it *vaultItem, filter map[string]any) bool {
+	d := it.data(false)
+	for key, want := range filter {
+		w := fmt.Sprint(want)
+		field, contains := strings.CutSuffix(key, "__contains")
+		if field == "folder" {
+			w = normalizeFolder(w)
+		}
+
+		if field == "tags" {
+			if !slices.Contains(it.Tags, w) {
+				return false
+			}
+			continue
+		}
+
+		got, ok := d[field]
+		if !ok {
+			return false
+		}
+		g := fmt.Sprint(got)
+		if contains && !strings.Contains(g, w) || !contains && g != w {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeFolder(folder string) string {
+	if folder == "" || folder == "/" {
+		return "/"
+	}
+	return strings.TrimSuffix(folder, "/")
+}
+
+func sign(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+func xorStream(key, data []byte) []byte {
+	stream := sha256.Sum256(key)
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[i] = b ^ stream[i%len(stream)]
+	}
+	return out
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
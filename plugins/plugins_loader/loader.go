@@ -4,6 +4,7 @@ import (
 	"github.com/pangeacyber/pangea-cli/v2/plugins"
 	"github.com/pangeacyber/pangea-cli/v2/plugins/intel"
 	"github.com/pangeacyber/pangea-cli/v2/plugins/profile"
+	"github.com/pangeacyber/pangea-cli/v2/plugins/request"
 	"github.com/pangeacyber/pangea-cli/v2/plugins/sync"
 	"github.com/pangeacyber/pangea-cli/v2/plugins/updates"
 	"github.com/pangeacyber/pangea-cli/v2/plugins/utils"
@@ -30,5 +31,6 @@ func LoadPlugins() []plugins.Plugin {
 		updates.PluginCheckUpdate,
 		updates.PluginUpdate,
 		profile.PluginProfile,
+		request.PluginRequest,
 	}
 }
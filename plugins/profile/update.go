@@ -9,8 +9,8 @@ import (
 
 var profileUpdateCmd = &cobra.Command{
 	Use:   "update",
-	Short: "Update a profile token or domain",
-	Long:  "Update a profile token or domain",
+	Short: "Update a profile token, domain or services",
+	Long:  "Update a profile token, domain or services",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		profileName, _ := cmd.Flags().GetString("profile")
 		serviceName, _ := cmd.Flags().GetString("service")
@@ -34,6 +34,15 @@ var profileUpdateCmd = &cobra.Command{
 			update = true
 		}
 
+		if cmd.Flags().Changed("services") {
+			services, _ := cmd.Flags().GetStringSlice("services")
+			err := cli.SaveServices(profileName, services)
+			if err != nil {
+				return err
+			}
+			update = true
+		}
+
 		if update && profileName == "" {
 			p, err := cli.GetCurrentProfileName()
 			if err == nil {
@@ -57,4 +66,5 @@ func init() {
 	profileUpdateCmd.Flags().StringP("service", "s", "default", "Service name to be updated. If omitted 'default' service will be updated.")
 	profileUpdateCmd.Flags().StringP("token", "t", "", "Token to be saved")
 	profileUpdateCmd.Flags().StringP("domain", "d", "", "Domain to be saved")
+	profileUpdateCmd.Flags().StringSlice("services", nil, fmt.Sprintf("Comma separated list of the services to load with the profile. Prefix a service with '-' to disable it. Set it empty to load all of them. Overridden by %s environment variable.", cli.ServicesEnvVar))
 }
@@ -0,0 +1,102 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pangeacyber/pangea-cli/v2/builder"
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/pangeacyber/pangea-cli/v2/plugins"
+	pangea "github.com/pangeacyber/pangea-go/pangea-sdk/v3/pangea"
+	"github.com/spf13/cobra"
+)
+
+const flagService = "service"
+
+// Default time `request wait` waits for a result
+const defaultWaitTimeout = 5 * time.Minute
+
+var PluginRequest = plugins.NewPlugin(requestCmd, []string{"request"})
+
+var requestCmd = &cobra.Command{
+	Use:     "request",
+	Short:   "Get the result of queued requests",
+	Long:    "Get the result of requests sent with '--async' or that were still in progress when their poll timeout expired.",
+	GroupID: "tools",
+}
+
+var requestPollCmd = &cobra.Command{
+	Use:   "poll <request_id>",
+	Short: "Request once the result of a queued request",
+	Long:  "Request once the result of a queued request. If it's still in progress, its request ID and 'Accepted' status are printed.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		env, err := builder.PollRequest(context.Background(), client, args[0])
+		if err != nil {
+			return err
+		}
+		return builder.PrintEnvelope(cmd, env, cli.RenderOptions{})
+	},
+}
+
+var requestWaitCmd = &cobra.Command{
+	Use:   "wait <request_id>",
+	Short: "Wait for the result of a queued request",
+	Long:  "Poll the result of a queued request until it's completed. Fails if it's still in progress after '--poll-timeout'.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		timeout, _ := cmd.Flags().GetDuration(builder.FlagPollTimeout)
+		env, err := builder.WaitRequest(context.Background(), client, args[0], timeout)
+		if err != nil {
+			return err
+		}
+		return builder.PrintEnvelope(cmd, env, cli.RenderOptions{})
+	},
+}
+
+func init() {
+	requestCmd.PersistentFlags().StringP(flagService, "s", "", "Service the request was sent to. Example: file-intel")
+	requestCmd.PersistentFlags().String(builder.FlagCLIProfile, "", "Use a particular CLI profile token and domain. Setup profile with 'pangea admin profile' commands.")
+	_ = requestCmd.MarkPersistentFlagRequired(flagService)
+
+	requestWaitCmd.Flags().Duration(builder.FlagPollTimeout, defaultWaitTimeout, "Maximum time to wait for the result")
+
+	requestCmd.AddCommand(requestPollCmd)
+	requestCmd.AddCommand(requestWaitCmd)
+}
+
+func getClient(cmd *cobra.Command) (*pangea.Client, error) {
+	svc, _ := cmd.Flags().GetString(flagService)
+	if svc == "" {
+		return nil, errors.New("service should be set")
+	}
+	profile, _ := cmd.Flags().GetString(builder.FlagCLIProfile)
+
+	var token, domain string
+	var err error
+	if profile != "" {
+		token, domain, err = cli.GetProfileTokenAndDomain(profile, svc)
+	} else {
+		token, domain, err = cli.GetTokenAndDomain(svc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s token. Error: %w", svc, err)
+	}
+
+	config := cli.GetDefaultPangeaConfig()
+	config.Token = token
+	cli.SetDomain(&config, domain)
+	return pangea.NewClient(svc, &config), nil
+}
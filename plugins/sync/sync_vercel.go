@@ -44,7 +44,10 @@ var vercelCmd = &cobra.Command{
 			logger.Fatal("Vercel token and project ID must be provided either as flags or environment variables.")
 		}
 
-		envs := vault.GetWorkspaceSecrets(vault.GetWorkspaceFromSettings())
+		envs, err := vault.GetWorkspaceSecrets(vault.GetWorkspaceFromSettings())
+		if err != nil {
+			logger.Fatalf("Error fetching secrets from Vault workspace: %s\n", err.Error())
+		}
 		if err := pushEnvToVercel(envs); err != nil {
 			logger.Fatalf("Error while syncing secrets from Vault workspace to vercel: %s\n", err.Error())
 		}
@@ -75,7 +78,7 @@ func pushEnvToVercel(envs []string) error {
 		return nil
 	}
 
-	client := &http.Client{}
+	client := cli.HTTPClient()
 	tokenHeaderValue := fmt.Sprintf("Bearer %s", vercelToken)
 
 	// Fetch project secrets
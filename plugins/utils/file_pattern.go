@@ -96,7 +96,15 @@ func (p *pluginWithFilePattern) Init(b plugins.CommandProvider) error {
 		return nil
 	})
 
+	// Keep base command pre run, it loads the request body
+	basePreRunE := cmd.PreRunE
 	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if basePreRunE != nil {
+			if err := basePreRunE(cmd, args); err != nil {
+				return err
+			}
+		}
+
 		patterns, err := cli.ReadAsCSV(args[0])
 		if err != nil {
 			return err
@@ -0,0 +1,336 @@
+package vault
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	pangea "github.com/pangeacyber/pangea-go/pangea-sdk/v3/pangea"
+	sv "github.com/pangeacyber/pangea-go/pangea-sdk/v3/service/vault"
+)
+
+// Template env file used by `vault workspace run` if it's on the current directory
+const defaultEnvTemplate = ".env.pangea"
+
+// Scheme of the secret references on env templates
+const secretRefScheme = "pangea"
+
+// secretRef points to a secret on Pangea Vault, by item ID like `pangea://vault/pvi_xxx`, or by
+// workspace and name like `pangea://workspace/dev/DB_PASSWORD`. Both of them take an optional
+// `#version=<n>` fragment, or the current version is used.
+type secretRef struct {
+	ID        string
+	Workspace string
+	Name      string
+	Version   string
+}
+
+// parseSecretRef parses a secret reference. It returns nil if value is not a reference.
+func parseSecretRef(value string) (*secretRef, error) {
+	if !strings.HasPrefix(value, secretRefScheme+"://") {
+		return nil, nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret reference '%s': %w", value, err)
+	}
+
+	ref := &secretRef{}
+	if u.Fragment != "" {
+		params, err := url.ParseQuery(u.Fragment)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret reference '%s': %w", value, err)
+		}
+		for key := range params {
+			if key != "version" {
+				return nil, fmt.Errorf("invalid secret reference '%s': unknown parameter '%s'", value, key)
+			}
+		}
+		ref.Version = params.Get("version")
+		if n, err := strconv.Atoi(ref.Version); err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid secret reference '%s': version should be a positive number", value)
+		}
+	}
+
+	path := strings.Trim(u.Path, "/")
+	switch u.Host {
+	case "vault":
+		if path == "" || strings.Contains(path, "/") {
+			return nil, fmt.Errorf("invalid secret reference '%s': expected pangea://vault/<item ID>", value)
+		}
+		ref.ID = path
+	case "workspace":
+		workspace, name, ok := cutLast(path, "/")
+		if !ok || workspace == "" || name == "" {
+			return nil, fmt.Errorf("invalid secret reference '%s': expected pangea://workspace/<workspace>/<name>", value)
+		}
+		ref.Workspace = "/" + workspace + "/"
+		ref.Name = name
+	default:
+		return nil, fmt.Errorf("invalid secret reference '%s': expected pangea://vault/ or pangea://workspace/", value)
+	}
+	return ref, nil
+}
+
+func (r *secretRef) String() string {
+	var s string
+	if r.ID != "" {
+		s = r.ID
+	} else {
+		s = fmt.Sprintf("%s on workspace %s", r.Name, r.Workspace)
+	}
+	if r.Version != "" {
+		s = fmt.Sprintf("%s (version %s)", s, r.Version)
+	}
+	return s
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return "", s, false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+// readEnvTemplate reads the variables of an env file, with `[export ]NAME=value` lines. Values
+// could be single quoted, taken as is, or double quoted, with `\n`, `\"` and `\\` escapes. On
+// unquoted values, comments start with a `#` after a blank, so references keep their fragment.
+func readEnvTemplate(r io.Reader) (map[string]string, error) {
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("line %d: expected NAME=value", n)
+		}
+
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		vars[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+func parseEnvValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", errors.New("unterminated single quoted value")
+		}
+		return value[1 : end+1], nil
+	case strings.HasPrefix(value, `"`):
+		var b strings.Builder
+		for i := 1; i < len(value); i++ {
+			c := value[i]
+			switch {
+			case c == '"':
+				return b.String(), nil
+			case c == '\\' && i+1 < len(value):
+				i++
+				switch value[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(value[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", errors.New("unterminated double quoted value")
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	if i := strings.Index(value, "\t#"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// secretResolver reads the secrets referenced on env templates from Pangea Vault. Workspace
+// listings are kept, so each workspace is listed once.
+type secretResolver struct {
+	client     *pangea.Client
+	vault      sv.Client
+	workspaces map[string]map[string]string
+}
+
+func newSecretResolver() (*secretResolver, error) {
+	config, err := vaultConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &secretResolver{
+		client:     pangea.NewClient("vault", config),
+		vault:      sv.New(config),
+		workspaces: map[string]map[string]string{},
+	}, nil
+}
+
+// resolveEnvTemplate returns the variables of a template as `NAME=value`, sorted by name, with
+// their secret references replaced by the secret values. Plain values are kept as they are. All
+// the references that could not be resolved are reported on the error.
+func resolveEnvTemplate(vars map[string]string) ([]string, error) {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var resolver *secretResolver
+	env := make([]string, 0, len(vars))
+	errs := []error{}
+	for _, name := range names {
+		value := vars[name]
+		ref, err := parseSecretRef(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		if ref != nil {
+			if resolver == nil {
+				if resolver, err = newSecretResolver(); err != nil {
+					return nil, err
+				}
+			}
+			value, err = resolver.resolve(context.Background(), ref)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+		}
+		env = append(env, fmt.Sprintf("%s=%s", name, value))
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to resolve secret references:\n%w", errors.Join(errs...))
+	}
+	return env, nil
+}
+
+func (r *secretResolver) resolve(ctx context.Context, ref *secretRef) (string, error) {
+	id := ref.ID
+	if id == "" {
+		ids, err := r.workspaceSecrets(ctx, ref.Workspace)
+		if err != nil {
+			return "", err
+		}
+		if id = ids[ref.Name]; id == "" {
+			return "", fmt.Errorf("secret %s not found", ref)
+		}
+	}
+	return r.getSecret(ctx, id, ref)
+}
+
+// workspaceSecrets returns the IDs of the secrets on a workspace by name.
+func (r *secretResolver) workspaceSecrets(ctx context.Context, workspace string) (map[string]string, error) {
+	if ids, ok := r.workspaces[workspace]; ok {
+		return ids, nil
+	}
+
+	ids, err := workspaceSecretIDs(ctx, r.vault, workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets on workspace %s: %w", workspace, err)
+	}
+	r.workspaces[workspace] = ids
+	return ids, nil
+}
+
+// getSecret returns the value of a secret, on the version set on the reference or the current one.
+func (r *secretResolver) getSecret(ctx context.Context, id string, ref *secretRef) (string, error) {
+	u, err := r.client.GetURL("/v1/get")
+	if err != nil {
+		return "", err
+	}
+	body := map[string]any{"id": id}
+	if ref.Version != "" {
+		body["version"] = ref.Version
+	}
+	req, err := r.client.NewRequest("POST", u, body)
+	if err != nil {
+		return "", err
+	}
+
+	type itemVersion struct {
+		Version int     `json:"version"`
+		Secret  *string `json:"secret"`
+	}
+	var result struct {
+		Type           string        `json:"type"`
+		CurrentVersion *itemVersion  `json:"current_version"`
+		Versions       []itemVersion `json:"versions"`
+	}
+	if _, err := r.client.Do(ctx, req, &result, true); err != nil {
+		var apiErr *pangea.APIError
+		if errors.As(err, &apiErr) && apiErr.Response != nil && apiErr.Response.Summary != nil {
+			return "", fmt.Errorf("failed to get secret %s: %s", ref, *apiErr.Response.Summary)
+		}
+		return "", fmt.Errorf("failed to get secret %s: %w", ref, err)
+	}
+
+	if result.Type != "secret" {
+		return "", fmt.Errorf("%s is a %s, not a secret", ref, result.Type)
+	}
+
+	// Requested versions are returned on `versions`, and `current_version` is always the current one
+	version := result.CurrentVersion
+	if ref.Version != "" {
+		version = nil
+		for i := range result.Versions {
+			if strconv.Itoa(result.Versions[i].Version) == ref.Version {
+				version = &result.Versions[i]
+			}
+		}
+		if version == nil {
+			return "", fmt.Errorf("secret %s not found", ref)
+		}
+	}
+	if version == nil || version.Secret == nil {
+		return "", fmt.Errorf("secret %s has no value", ref)
+	}
+	return *version.Secret, nil
+}
+
+// readEnvTemplateFile reads an env template, or returns nil if name is empty.
+func readEnvTemplateFile(name string) (map[string]string, error) {
+	if name == "" {
+		return nil, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	vars, err := readEnvTemplate(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return vars, nil
+}
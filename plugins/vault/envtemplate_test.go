@@ -0,0 +1,55 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSecretRef(t *testing.T) {
+	ref, err := parseSecretRef("pangea://vault/pvi_abc")
+	assert.NoError(t, err)
+	assert.Equal(t, &secretRef{ID: "pvi_abc"}, ref)
+
+	ref, err = parseSecretRef("pangea://workspace/apps/dev/DB_PASSWORD#version=3")
+	assert.NoError(t, err)
+	assert.Equal(t, &secretRef{Workspace: "/apps/dev/", Name: "DB_PASSWORD", Version: "3"}, ref)
+
+	ref, err = parseSecretRef("postgres://localhost:5432")
+	assert.NoError(t, err)
+	assert.Nil(t, ref)
+
+	for _, value := range []string{
+		"pangea://vault/",
+		"pangea://workspace/DB_PASSWORD",
+		"pangea://vault/pvi_abc#version=last",
+		"pangea://vault/pvi_abc#v=1",
+		"pangea://audit/pvi_abc",
+	} {
+		_, err := parseSecretRef(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestReadEnvTemplate(t *testing.T) {
+	vars, err := readEnvTemplate(strings.NewReader(`
+# Database
+export DB_PASS=pangea://workspace/dev/DB_PASSWORD#version=3
+DB_USER=admin # comment
+GREETING="hello \"world\"\n"
+RAW='a$b #c'
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"DB_PASS":  "pangea://workspace/dev/DB_PASSWORD#version=3",
+		"DB_USER":  "admin",
+		"GREETING": "hello \"world\"\n",
+		"RAW":      "a$b #c",
+	}, vars)
+
+	_, err = readEnvTemplate(strings.NewReader("A=1\nB\n"))
+	assert.ErrorContains(t, err, "line 2")
+	_, err = readEnvTemplate(strings.NewReader(`A="unterminated`))
+	assert.Error(t, err)
+}
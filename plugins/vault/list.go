@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/pangeacyber/pangea-cli/v2/builder"
+	"github.com/pangeacyber/pangea-cli/v2/cli"
 	"github.com/pangeacyber/pangea-cli/v2/plugins"
 	sv "github.com/pangeacyber/pangea-go/pangea-sdk/v3/service/vault"
 	"github.com/spf13/cobra"
@@ -52,6 +54,21 @@ var listCmd = &cobra.Command{
 			return err
 		}
 
+		if builder.StructuredOutputRequested(cmd) {
+			items := make([]map[string]any, 0, len(resp.Result.Items))
+			for _, item := range resp.Result.Items {
+				items = append(items, map[string]any{
+					"name":       item.Name,
+					"id":         item.ID,
+					"created_at": item.CreatedAt,
+				})
+			}
+			return builder.Print(cmd, map[string]any{"items": items}, cli.RenderOptions{
+				ListField:   "items",
+				ListColumns: []string{"name", "id", "created_at"},
+			})
+		}
+
 		for _, item := range resp.Result.Items {
 			fmt.Println(item.Name)
 			if details {
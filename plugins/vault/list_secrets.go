@@ -6,6 +6,8 @@ import (
 	"log"
 	"strings"
 
+	"github.com/pangeacyber/pangea-cli/v2/builder"
+	"github.com/pangeacyber/pangea-cli/v2/cli"
 	"github.com/pangeacyber/pangea-cli/v2/plugins"
 	"github.com/spf13/cobra"
 )
@@ -31,7 +33,29 @@ var lsCmd = &cobra.Command{
 			workspace = GetWorkspaceFromSettings()
 		}
 
-		remoteEnv := GetWorkspaceSecrets(workspace)
+		remoteEnv, err := GetWorkspaceSecrets(workspace)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if builder.StructuredOutputRequested(cmd) {
+			items := make([]map[string]any, 0, len(remoteEnv))
+			for _, envVar := range remoteEnv {
+				name, value, _ := strings.Cut(envVar, "=")
+				if !show {
+					value = "********"
+				}
+				items = append(items, map[string]any{"name": name, "value": value})
+			}
+			err := builder.Print(cmd, map[string]any{"items": items}, cli.RenderOptions{
+				ListField:   "items",
+				ListColumns: []string{"name", "value"},
+			})
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 
 		for _, envVar := range remoteEnv {
 			if !show {
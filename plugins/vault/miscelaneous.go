@@ -2,11 +2,15 @@ package vault
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/pangeacyber/pangea-cli/v2/cli"
+	pangea "github.com/pangeacyber/pangea-go/pangea-sdk/v3/pangea"
 	sv "github.com/pangeacyber/pangea-go/pangea-sdk/v3/service/vault"
 )
 
@@ -22,15 +26,23 @@ func GetWD() string {
 }
 
 func CreateVaultService() (sv.Client, error) {
+	config, err := vaultConfig()
+	if err != nil {
+		return nil, err
+	}
+	return sv.New(config), nil
+}
+
+// vaultConfig returns the config of the clients of the Vault service.
+func vaultConfig() (*pangea.Config, error) {
 	token, domain, err := cli.GetTokenAndDomain("vault")
 	if err != nil {
 		return nil, err
 	}
 	config := cli.GetDefaultPangeaConfig()
 	config.Token = token
-	config.Domain = domain
-
-	return sv.New(&config), nil
+	cli.SetDomain(&config, domain)
+	return &config, nil
 }
 
 func GetWorkspaceFromSettings() string {
@@ -56,58 +68,120 @@ func GetWorkspaceFromSettings() string {
 	return workspace
 }
 
-func GetWorkspaceSecrets(workspace string) []string {
-	var remoteEnv []string
+// Number of secrets read at the same time by GetWorkspaceSecrets
+const secretsConcurrency = 8
 
-	if workspace != "" {
-		logger.Printf("Fetching secrets from: %s\n", workspace)
-		client, err := CreateVaultService()
-		if err != nil {
-			return []string{}
+var errNoWorkspace = errors.New("folder not found. Please use `pangea vault workspace select` to choose the workspace you would like to use secrets from")
+
+// GetWorkspaceSecrets returns the secrets on a workspace as `NAME=value`, sorted by name.
+func GetWorkspaceSecrets(workspace string) ([]string, error) {
+	if workspace == "" {
+		return nil, errNoWorkspace
+	}
+	logger.Printf("Fetching secrets from: %s\n", workspace)
+	client, err := CreateVaultService()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	items, err := listWorkspaceSecrets(ctx, client, workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets on workspace %s: %w", workspace, err)
+	}
+	return getSecrets(ctx, client, items)
+}
+
+// getSecrets reads the current value of the secrets, secretsConcurrency of them at the same time,
+// and returns them as `NAME=value` sorted by name. All the secrets that could not be read are
+// reported on the error.
+func getSecrets(ctx context.Context, client sv.Client, items []sv.ListItemData) ([]string, error) {
+	values := make([]*string, len(items))
+	errs := make([]error, len(items))
+	sem := make(chan struct{}, secretsConcurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, item sv.ListItemData) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			resp, err := client.Get(ctx, &sv.GetRequest{ID: item.ID})
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", item.Name, err)
+				return
+			}
+			if resp.Result.CurrentVersion != nil {
+				values[i] = resp.Result.CurrentVersion.Secret
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("failed to get secrets:\n%w", err)
+	}
+
+	secrets := map[string]string{}
+	for i, item := range items {
+		if values[i] != nil {
+			secrets[item.Name] = *values[i]
 		}
+	}
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-		resp, err := client.List(
-			context.Background(),
-			&sv.ListRequest{
-				Filter: map[string]string{
-					"folder": workspace,
-				},
+	env := make([]string, 0, len(names))
+	for _, name := range names {
+		env = append(env, fmt.Sprintf("%s=%s", name, secrets[name]))
+	}
+	return env, nil
+}
+
+// workspaceSecretIDs returns the IDs of the secrets on a workspace by name.
+func workspaceSecretIDs(ctx context.Context, client sv.Client, workspace string) (map[string]string, error) {
+	items, err := listWorkspaceSecrets(ctx, client, workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[string]string{}
+	for _, item := range items {
+		ids[item.Name] = item.ID
+	}
+	return ids, nil
+}
+
+// listWorkspaceSecrets returns the secret items on a workspace, following the list pages.
+func listWorkspaceSecrets(ctx context.Context, client sv.Client, workspace string) ([]sv.ListItemData, error) {
+	items := []sv.ListItemData{}
+	last := ""
+	for {
+		resp, err := client.List(ctx, &sv.ListRequest{
+			Filter: map[string]string{
+				"folder": workspace,
 			},
-		)
+			Last: last,
+		})
 		if err != nil {
-			logger.Fatal("Error fetching secrets from Pangea")
+			return nil, err
 		}
 
 		if resp.Status != nil && *resp.Status == "Unauthorized" {
-			logger.Fatal("Unauthorized! Please run `pangea login` to get a new token.")
+			return nil, errors.New("unauthorized. Please run `pangea login` to get a new token")
 		}
 
-		// Create a list of secret type IDs
-		secretIDs := make(map[string]interface{})
 		for _, item := range resp.Result.Items {
 			if item.Type == "secret" {
-				secretIDs[item.ID] = item.Name
+				items = append(items, item)
 			}
 		}
-
-		// Print the list of secret type IDs
-		for id, val := range secretIDs {
-			resp, err := client.Get(
-				context.Background(),
-				&sv.GetRequest{
-					ID: id,
-				},
-			)
-			if err != nil {
-				logger.Fatal("Error fetching secret ", val)
-			}
-			if resp.Result.CurrentVersion.Secret != nil {
-				remoteEnv = append(remoteEnv, fmt.Sprintf("%s=%s", val, *resp.Result.CurrentVersion.Secret))
-			}
+		if resp.Result.Last == "" {
+			return items, nil
 		}
-	} else {
-		logger.Fatal("Folder not found. Please use `pangea vault workspace select` to choose the workspace you would like to use secrets from")
+		last = resp.Result.Last
 	}
-
-	return remoteEnv
 }
@@ -0,0 +1,106 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pangea "github.com/pangeacyber/pangea-go/pangea-sdk/v3/pangea"
+	sv "github.com/pangeacyber/pangea-go/pangea-sdk/v3/service/vault"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeVault lists its items two at a time, and counts the Get requests running at the same time.
+type fakeVault struct {
+	sv.Client
+	items   []sv.ListItemData
+	failing string
+
+	mu      sync.Mutex
+	running int
+	max     int
+	gets    atomic.Int32
+}
+
+func (v *fakeVault) List(ctx context.Context, input *sv.ListRequest) (*pangea.PangeaResponse[sv.ListResult], error) {
+	offset := 0
+	if input.Last != "" {
+		offset, _ = strconv.Atoi(input.Last)
+	}
+	end := min(offset+2, len(v.items))
+	result := &sv.ListResult{Items: v.items[offset:end], Count: end - offset}
+	if end < len(v.items) {
+		result.Last = strconv.Itoa(end)
+	}
+	return &pangea.PangeaResponse[sv.ListResult]{Result: result}, nil
+}
+
+func (v *fakeVault) Get(ctx context.Context, input *sv.GetRequest) (*pangea.PangeaResponse[sv.GetResult], error) {
+	v.gets.Add(1)
+	v.mu.Lock()
+	v.running++
+	v.max = max(v.max, v.running)
+	v.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	v.mu.Lock()
+	v.running--
+	v.mu.Unlock()
+
+	if input.ID == v.failing {
+		return nil, errors.New("not found")
+	}
+	secret := "value_" + input.ID
+	result := &sv.GetResult{}
+	result.ID = input.ID
+	result.CurrentVersion = &sv.ItemVersionData{Version: 1, Secret: &secret}
+	return &pangea.PangeaResponse[sv.GetResult]{Result: result}, nil
+}
+
+func newFakeVault(n int) *fakeVault {
+	v := &fakeVault{}
+	for i := n - 1; i >= 0; i-- {
+		item := sv.ListItemData{}
+		item.Type = "secret"
+		item.ID = fmt.Sprintf("pvi_%02d", i)
+		item.Name = fmt.Sprintf("SECRET_%02d", i)
+		v.items = append(v.items, item)
+	}
+	folder := sv.ListItemData{}
+	folder.Type = "folder"
+	folder.Name = "nested"
+	v.items = append(v.items, folder)
+	return v
+}
+
+func TestListWorkspaceSecrets(t *testing.T) {
+	v := newFakeVault(5)
+	items, err := listWorkspaceSecrets(context.Background(), v, "/app/")
+	assert.NoError(t, err)
+	assert.Len(t, items, 5)
+	assert.Equal(t, "SECRET_04", items[0].Name)
+	assert.Equal(t, "SECRET_00", items[4].Name)
+}
+
+func TestGetSecrets(t *testing.T) {
+	v := newFakeVault(30)
+	items, err := listWorkspaceSecrets(context.Background(), v, "/app/")
+	assert.NoError(t, err)
+
+	env, err := getSecrets(context.Background(), v, items)
+	assert.NoError(t, err)
+	assert.Len(t, env, 30)
+	assert.Equal(t, "SECRET_00=value_pvi_00", env[0])
+	assert.Equal(t, "SECRET_29=value_pvi_29", env[29])
+	assert.Equal(t, int32(30), v.gets.Load())
+	assert.LessOrEqual(t, v.max, secretsConcurrency)
+	assert.Greater(t, v.max, 1)
+
+	v.failing = "pvi_07"
+	_, err = getSecrets(context.Background(), v, items)
+	assert.ErrorContains(t, err, "SECRET_07: not found")
+}
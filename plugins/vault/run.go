@@ -3,11 +3,17 @@
 package vault
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
 
+	"github.com/pangeacyber/pangea-cli/v2/cli"
 	"github.com/pangeacyber/pangea-cli/v2/plugins"
 	"github.com/spf13/cobra"
 )
@@ -22,7 +28,27 @@ var runCmd = &cobra.Command{
 
 	For example:
 		pangea vault workspace run -- npm run dev
-			- will start your node server with secrets loaded in from Pangea`,
+			- will start your node server with secrets loaded in from Pangea
+
+	If one is set with '--env-file', or '--workspace' is not set and there is a .env.pangea template
+	file on the current directory, only its variables are loaded instead of the whole workspace. Values
+	could reference secrets, that are read from Pangea Vault when the application starts:
+		DB_USER=admin
+		DB_PASS=pangea://vault/pvi_xxx
+		API_KEY=pangea://workspace/dev/API_KEY#version=3
+
+	The CLI exits with the exit code of the application, and forwards it the signals it gets, so it
+	could be used as a container entrypoint. On Unix, '--exec' replaces the CLI process with the
+	application instead.
+
+	With '--watch', the secrets are checked every '--watch-interval', and the application is restarted
+	when any of them changes. Set '--on-change' to a signal, like SIGHUP, to send it to the application
+	after the secrets are written to '--secrets-file' instead.
+
+	With '--cache', the secrets of the workspace are saved encrypted on disk, and used instead of
+	reaching Pangea until they're older than '--cache-max-age'. They're sealed with a key on the OS
+	keyring, or with the passphrase on PANGEA_CLI_CACHE_PASSPHRASE environment variable if it's set.
+	'--offline' only uses cached secrets, and '--refresh' reads them from Pangea regardless of their age.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) < 1 {
 			return errors.New("no specified command")
@@ -33,51 +59,167 @@ var runCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		if workspace == "" {
-			workspace = GetWorkspaceFromSettings()
+		envFile, err := cmd.Flags().GetString("env-file")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if envFile != "" && workspace != "" {
+			return errors.New("only one of `workspace` or `env-file` flags could be set")
+		}
+		if envFile == "" && workspace == "" {
+			if _, err := os.Stat(defaultEnvTemplate); err == nil {
+				envFile = defaultEnvTemplate
+			}
 		}
 
-		baseCommand := args[0]
-		args = args[1:]
-		err = execSubprocess(workspace, baseCommand, args)
+		var source secretSource
+		if envFile != "" {
+			vars, err := readEnvTemplateFile(envFile)
+			if err != nil {
+				return err
+			}
+			logger.Printf("Resolving secrets from: %s\n", envFile)
+			source = &templateSource{vars: vars}
+		} else {
+			if workspace == "" {
+				workspace = GetWorkspaceFromSettings()
+			}
+			source = &workspaceSource{workspace: workspace}
+		}
+
+		execFlag, _ := cmd.Flags().GetBool("exec")
+		watch, _ := cmd.Flags().GetBool("watch")
+		if execFlag && watch {
+			return errors.New("only one of `exec` or `watch` flags could be set")
+		}
+		if source, err = withSecretCache(cmd, source); err != nil {
+			return err
+		}
+		var opts *watchOptions
+		if watch {
+			if opts, err = getWatchOptions(cmd); err != nil {
+				return err
+			}
+		}
+
+		remoteEnv, version, err := source.Load(context.Background())
 		if err != nil {
 			return err
 		}
-		return nil
+		if secretsFile, _ := cmd.Flags().GetString("secrets-file"); secretsFile != "" {
+			if err := writeSecretsFile(secretsFile, remoteEnv); err != nil {
+				return err
+			}
+		}
+
+		baseCommand := args[0]
+		args = args[1:]
+		if execFlag {
+			return execInPlace(remoteEnv, baseCommand, args)
+		}
+
+		// Errors from here on are the command ones
+		cmd.SilenceUsage = true
+		if watch {
+			err = watchSubprocess(source, remoteEnv, version, opts, baseCommand, args)
+		} else {
+			err = execSubprocess(remoteEnv, baseCommand, args)
+		}
+		var exitErr *cli.ExitError
+		if errors.As(err, &exitErr) {
+			cmd.SilenceErrors = true
+		}
+		return err
 	},
 }
 
-func execSubprocess(workspace string, baseCommand string, args []string) error {
-	cmd := exec.Command(baseCommand, args...)
-	remoteEnv := GetWorkspaceSecrets(workspace)
+// execSubprocess runs a command with the secrets added to the environment, as a transparent wrapper:
+// stdin and outputs are wired to the command, signals are forwarded to it, and its exit code is
+// returned as a *cli.ExitError.
+func execSubprocess(remoteEnv []string, baseCommand string, args []string) error {
+	// Listen before starting the command, so signals are not missed
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
 
-	env := make([]string, len(os.Environ())+len(remoteEnv))
-	copy(env, os.Environ())
-	env = append(env, remoteEnv...)
-	cmd.Env = env
+	cmd, group, err := startSubprocess(remoteEnv, baseCommand, args)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				forwardSignal(cmd.Process, sig, group)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+	close(done)
+	return subprocessError(err)
+}
 
-	// Set up pipes for stdout and stderr
+// startSubprocess starts a command with the secrets added to the environment. Commands run on their
+// own process group, unless they're interactive. Those ones stay on the terminal foreground process
+// group, so they can read from it.
+func startSubprocess(remoteEnv []string, baseCommand string, args []string) (cmd *exec.Cmd, group bool, err error) {
+	cmd = exec.Command(baseCommand, args...)
+	cmd.Env = mergeEnv(os.Environ(), remoteEnv)
+	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	// Start the subprocess
-	err := cmd.Start()
-	if err != nil {
-		log.Fatal("Error starting subprocess:", err)
-		return err
+	group = !isTerminal(os.Stdin)
+	setProcessGroup(cmd, group)
+
+	if err := cmd.Start(); err != nil {
+		return nil, false, fmt.Errorf("failed to start %s: %w", baseCommand, err)
 	}
+	return cmd, group, nil
+}
 
-	// Wait for the subprocess to finish
-	err = cmd.Wait()
-	if err != nil {
-		log.Fatal("Error waiting for subprocess:", err)
-		return err
+// subprocessError returns the exit code of a finished command as a *cli.ExitError.
+func subprocessError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &cli.ExitError{Code: exitCode(exitErr.ProcessState)}
 	}
+	return err
+}
 
-	// Subprocess has completed
-	return nil
+// mergeEnv appends variables to an environment. Appended variables replace the ones with their name.
+func mergeEnv(env []string, vars []string) []string {
+	merged := make([]string, 0, len(env)+len(vars))
+	index := map[string]int{}
+	for _, v := range slices.Concat(env, vars) {
+		name, _, _ := strings.Cut(v, "=")
+		if i, ok := index[name]; ok {
+			merged[i] = v
+			continue
+		}
+		index[name] = len(merged)
+		merged = append(merged, v)
+	}
+	return merged
 }
 
 func init() {
 	runCmd.Flags().StringP("workspace", "w", "", "Overwrite 'workspace' selected with 'pangea vault workspace select'")
+	runCmd.Flags().Bool("watch", false, "Check the secrets periodically and restart the command, or signal it, when they change")
+	runCmd.Flags().Duration("watch-interval", defaultWatchInterval, "Time between secrets checks on '--watch' mode")
+	runCmd.Flags().String("on-change", onChangeRestart, "What to do when secrets change on '--watch' mode: 'restart' the command, or send it a signal like 'SIGHUP'")
+	runCmd.Flags().String("secrets-file", "", "File the secrets are written to, in .env format, when the command starts and when they change on '--watch' mode. Required to send signals on change")
+	runCmd.Flags().Duration("debounce", defaultWatchDebounce, "Time secrets must stay unchanged before changes are applied on '--watch' mode")
+	runCmd.Flags().Int("max-restarts", defaultMaxRestarts, "Stop watching the secrets after restarting the command this many times. 0 means no limit")
+	runCmd.Flags().Bool("cache", false, "Save the secrets of the workspace encrypted on disk, and use them while they're newer than '--cache-max-age'")
+	runCmd.Flags().Duration("cache-max-age", defaultSecretCacheMaxAge, "Time cached secrets are used for")
+	runCmd.Flags().Bool("offline", false, "Only use cached secrets, without reaching Pangea. Implies '--cache'")
+	runCmd.Flags().Bool("refresh", false, "Read the secrets from Pangea and update the cache, even if cached ones are still fresh. Implies '--cache'")
+	runCmd.Flags().Bool("exec", false, "Replace the CLI process with the command, instead of running it as a child process. Not supported on Windows")
+	runCmd.Flags().StringP("env-file", "e", "", fmt.Sprintf("Template env file with the variables to load, whose 'pangea://' secret references are resolved. Defaults to '%s' if it exists and '--workspace' is not set", defaultEnvTemplate))
 }
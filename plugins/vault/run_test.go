@@ -0,0 +1,12 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeEnv(t *testing.T) {
+	env := mergeEnv([]string{"PATH=/bin", "DB_PASS=old", "HOME=/root"}, []string{"DB_PASS=new", "API_KEY=key"})
+	assert.Equal(t, []string{"PATH=/bin", "DB_PASS=new", "HOME=/root", "API_KEY=key"}, env)
+}
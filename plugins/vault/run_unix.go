@@ -0,0 +1,111 @@
+//go:build unix
+
+package vault
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"golang.org/x/sys/unix"
+)
+
+// Signals forwarded to the commands run with the secrets
+var forwardedSignals = []os.Signal{
+	syscall.SIGINT,
+	syscall.SIGTERM,
+	syscall.SIGHUP,
+	syscall.SIGQUIT,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+}
+
+// setProcessGroup runs the command on its own process group, so signals reach its children too.
+func setProcessGroup(cmd *exec.Cmd, group bool) {
+	if group {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	}
+}
+
+// isTerminal returns whether a file is a terminal. Other character devices, like /dev/null, are not.
+func isTerminal(f *os.File) bool {
+	_, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	return err == nil
+}
+
+// forwardSignal sends a signal to the process group of the command. Commands on the terminal
+// process group already got the signals sent from the terminal, so only the rest are sent to them.
+func forwardSignal(p *os.Process, sig os.Signal, group bool) {
+	if group {
+		_ = syscall.Kill(-p.Pid, sig.(syscall.Signal))
+		return
+	}
+	if sig == syscall.SIGINT || sig == syscall.SIGQUIT {
+		return
+	}
+	_ = p.Signal(sig)
+}
+
+// Signals that could be sent to commands when secrets change, by name
+var signalNames = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// parseSignal returns a signal by name, with or without `SIG` prefix, or number.
+func parseSignal(name string) (os.Signal, error) {
+	if n, err := strconv.Atoi(name); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	if sig, ok := signalNames[strings.TrimPrefix(strings.ToUpper(name), "SIG")]; ok {
+		return sig, nil
+	}
+	return nil, fmt.Errorf("unknown signal '%s'", name)
+}
+
+// stopSubprocess asks a command to exit with SIGTERM.
+func stopSubprocess(p *os.Process, group bool) {
+	forwardSignal(p, syscall.SIGTERM, group)
+}
+
+func killSubprocess(p *os.Process, group bool) {
+	if group {
+		_ = syscall.Kill(-p.Pid, syscall.SIGKILL)
+		return
+	}
+	_ = p.Kill()
+}
+
+// exitCode returns the exit code of a process, or 128 plus the signal number if it was killed by one,
+// as shells do.
+func exitCode(state *os.ProcessState) int {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return state.ExitCode()
+}
+
+// execInPlace replaces the CLI process with the command.
+func execInPlace(remoteEnv []string, baseCommand string, args []string) error {
+	path, err := exec.LookPath(baseCommand)
+	if err != nil {
+		return err
+	}
+	if err := cli.FlushTrace(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write trace file. Error: %v\n", err)
+	}
+
+	argv := append([]string{baseCommand}, args...)
+	if err := syscall.Exec(path, argv, mergeEnv(os.Environ(), remoteEnv)); err != nil {
+		return fmt.Errorf("failed to exec %s: %w", baseCommand, err)
+	}
+	return nil
+}
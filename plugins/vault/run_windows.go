@@ -0,0 +1,45 @@
+//go:build windows
+
+package vault
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// Console signals reach the commands too, so they are only caught to keep the CLI running until
+// the command ends
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+func setProcessGroup(cmd *exec.Cmd, group bool) {}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+func forwardSignal(p *os.Process, sig os.Signal, group bool) {}
+
+func parseSignal(name string) (os.Signal, error) {
+	return nil, errors.New("signals are not supported on Windows")
+}
+
+func stopSubprocess(p *os.Process, group bool) {
+	_ = p.Kill()
+}
+
+func killSubprocess(p *os.Process, group bool) {
+	_ = p.Kill()
+}
+
+func exitCode(state *os.ProcessState) int {
+	return state.ExitCode()
+}
+
+func execInPlace(remoteEnv []string, baseCommand string, args []string) error {
+	return errors.New("--exec is not supported on Windows")
+}
@@ -0,0 +1,319 @@
+package vault
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/spf13/cobra"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Environment variable with the passphrase the secret cache is sealed with, instead of a key saved on
+// the OS keyring
+const cachePassphraseEnvVar = "PANGEA_CLI_CACHE_PASSPHRASE"
+
+const defaultSecretCacheMaxAge = 24 * time.Hour
+
+// Folder, inside the cache one, where the encrypted secrets of workspaces are saved
+const secretCacheFolder = "secrets"
+
+// OS keyring entry with the key of the secret cache
+const (
+	keyringService = "pangea-cli"
+	keyringUser    = "secret-cache"
+)
+
+// Sources of the key the secrets are sealed with
+const (
+	keySourceKeyring    = "keyring"
+	keySourcePassphrase = "passphrase"
+)
+
+// Cost parameters of the keys derived from passphrases
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+const cacheKeySize = 32
+
+// sealedSecrets is the content of a secret cache file. Its ciphertext is a cachedSecrets encrypted
+// with AES-GCM, bound to the profile and workspace of the file.
+type sealedSecrets struct {
+	KeySource  string `json:"key_source"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type cachedSecrets struct {
+	Env       []string  `json:"env"`
+	Version   string    `json:"version"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// secretCache keeps the secrets of a workspace of a profile encrypted on disk, so they could be used
+// without reaching Pangea until they're older than maxAge.
+type secretCache struct {
+	profile   string
+	workspace string
+	filename  string
+	maxAge    time.Duration
+}
+
+func newSecretCache(workspace string, maxAge time.Duration) (*secretCache, error) {
+	profile, err := cli.GetCurrentProfileName()
+	if err != nil || profile == "" {
+		profile = "default"
+	}
+	cacheDir, err := cli.GetCacheFolder()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(profile + "\n" + workspace))
+	return &secretCache{
+		profile:   profile,
+		workspace: workspace,
+		filename:  filepath.Join(cacheDir, secretCacheFolder, hex.EncodeToString(sum[:16])+".json"),
+		maxAge:    maxAge,
+	}, nil
+}
+
+// load returns the cached secrets, or nil if there are none. Stale secrets are returned too, and kept on
+// disk until they're refreshed or the cache is cleaned.
+func (c *secretCache) load() (*cachedSecrets, error) {
+	b, err := os.ReadFile(c.filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sealed sealedSecrets
+	if err := json.Unmarshal(b, &sealed); err != nil {
+		return nil, fmt.Errorf("invalid secret cache file %s: %w", c.filename, err)
+	}
+	key, err := cacheKey(sealed.KeySource, sealed.Salt, false)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, sealed.Nonce, sealed.Ciphertext, c.additionalData())
+	if err != nil {
+		key := "passphrase"
+		if sealed.KeySource == keySourceKeyring {
+			key = "key on the OS keyring"
+		}
+		return nil, fmt.Errorf("failed to decrypt cached secrets of workspace %s. The %s is not the one they were sealed with", c.workspace, key)
+	}
+
+	var cached cachedSecrets
+	if err := json.Unmarshal(plaintext, &cached); err != nil {
+		return nil, fmt.Errorf("invalid secret cache file %s: %w", c.filename, err)
+	}
+	return &cached, nil
+}
+
+// stale returns true if the cached secrets are older than maxAge.
+func (c *secretCache) stale(cached *cachedSecrets) bool {
+	return time.Since(cached.FetchedAt) >= c.maxAge
+}
+
+// save seals the secrets with the passphrase if it's set, or with a key on the OS keyring.
+func (c *secretCache) save(env []string, version string) error {
+	source := keySourceKeyring
+	if os.Getenv(cachePassphraseEnvVar) != "" {
+		source = keySourcePassphrase
+	}
+	sealed := sealedSecrets{KeySource: source}
+	if source == keySourcePassphrase {
+		sealed.Salt = make([]byte, 16)
+		if _, err := rand.Read(sealed.Salt); err != nil {
+			return err
+		}
+	}
+
+	key, err := cacheKey(source, sealed.Salt, true)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	cached := cachedSecrets{Env: env, Version: version, FetchedAt: time.Now().UTC()}
+	plaintext, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	sealed.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(sealed.Nonce); err != nil {
+		return err
+	}
+	sealed.Ciphertext = gcm.Seal(nil, sealed.Nonce, plaintext, c.additionalData())
+
+	b, err := json.Marshal(sealed)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.filename), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.filename, b, 0600); err != nil {
+		return err
+	}
+
+	return cli.CacheSetSecrets(filepath.Base(c.filename), &cli.SecretCacheData{
+		Profile:   c.profile,
+		Workspace: c.workspace,
+		KeySource: source,
+		Count:     len(env),
+		FetchedAt: cached.FetchedAt,
+	})
+}
+
+// additionalData binds the sealed secrets to their profile and workspace, so they could not be
+// loaded for others by swapping files.
+func (c *secretCache) additionalData() []byte {
+	return []byte(c.profile + "\n" + c.workspace)
+}
+
+// cacheKey returns the key the secrets are sealed with. Keyring keys are created if there is none
+// and create is set.
+func cacheKey(source string, salt []byte, create bool) ([]byte, error) {
+	switch source {
+	case keySourcePassphrase:
+		passphrase := os.Getenv(cachePassphraseEnvVar)
+		if passphrase == "" {
+			return nil, fmt.Errorf("cached secrets are sealed with a passphrase. Set it on %s", cachePassphraseEnvVar)
+		}
+		return scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, cacheKeySize)
+
+	case keySourceKeyring:
+		encoded, err := keyring.Get(keyringService, keyringUser)
+		if errors.Is(err, keyring.ErrNotFound) && create {
+			key := make([]byte, cacheKeySize)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+			if err := keyring.Set(keyringService, keyringUser, base64.StdEncoding.EncodeToString(key)); err != nil {
+				return nil, keyringError(err)
+			}
+			return key, nil
+		}
+		if err != nil {
+			return nil, keyringError(err)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != cacheKeySize {
+			return nil, errors.New("invalid secret cache key on the OS keyring")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown secret cache key source '%s'", source)
+}
+
+func keyringError(err error) error {
+	return fmt.Errorf("failed to read the secret cache key from the OS keyring: %w. Set %s to seal the cache with a passphrase instead", err, cachePassphraseEnvVar)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// withSecretCache returns a source that uses the secret cache if any of the `cache`, `offline` or
+// `refresh` flags are set, or source as it is otherwise.
+func withSecretCache(cmd *cobra.Command, source secretSource) (secretSource, error) {
+	cache, _ := cmd.Flags().GetBool("cache")
+	offline, _ := cmd.Flags().GetBool("offline")
+	refresh, _ := cmd.Flags().GetBool("refresh")
+	if !cache && !offline && !refresh {
+		return source, nil
+	}
+
+	if offline && refresh {
+		return nil, errors.New("only one of `offline` or `refresh` flags could be set")
+	}
+	if watch, _ := cmd.Flags().GetBool("watch"); watch && offline {
+		return nil, errors.New("only one of `offline` or `watch` flags could be set")
+	}
+	maxAge, _ := cmd.Flags().GetDuration("cache-max-age")
+	if maxAge <= 0 {
+		return nil, errors.New("`cache-max-age` should be greater than 0")
+	}
+	ws, ok := source.(*workspaceSource)
+	if !ok {
+		return nil, errors.New("secrets are only cached for workspaces, not for env templates")
+	}
+	if ws.workspace == "" {
+		return nil, errNoWorkspace
+	}
+
+	c, err := newSecretCache(ws.workspace, maxAge)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedSource{workspaceSource: ws, cache: c, offline: offline, refresh: refresh}, nil
+}
+
+// cachedSource loads the secrets of a workspace from the encrypted cache while they're fresh, and
+// saves them to it when they're read from Pangea. Only the first load could come from the cache, so
+// the changes found on `watch` mode are read from Pangea.
+type cachedSource struct {
+	*workspaceSource
+	cache   *secretCache
+	offline bool
+	refresh bool
+}
+
+func (s *cachedSource) Load(ctx context.Context) ([]string, string, error) {
+	if !s.refresh {
+		s.refresh = true
+		cached, err := s.cache.load()
+		if err != nil {
+			if s.offline {
+				return nil, "", err
+			}
+			logger.Printf("Failed to read cached secrets: %v\n", err)
+		} else if cached != nil && !s.cache.stale(cached) {
+			logger.Printf("Using secrets of %s cached %v ago\n", s.workspace, time.Since(cached.FetchedAt).Round(time.Second))
+			return cached.Env, cached.Version, nil
+		}
+	}
+	if s.offline {
+		return nil, "", fmt.Errorf("no cached secrets of workspace %s newer than %v", s.workspace, s.cache.maxAge)
+	}
+
+	env, version, err := s.workspaceSource.Load(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.cache.save(env, version); err != nil {
+		logger.Printf("Failed to cache secrets: %v\n", err)
+	}
+	return env, version, nil
+}
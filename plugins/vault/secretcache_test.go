@@ -0,0 +1,102 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pangeacyber/pangea-cli/v2/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/zalando/go-keyring"
+)
+
+func newTestSecretCache(t *testing.T, workspace string) *secretCache {
+	c, err := newSecretCache(workspace, time.Hour)
+	assert.NoError(t, err)
+	return c
+}
+
+func TestSecretCachePassphrase(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(cachePassphraseEnvVar, "correct horse")
+
+	c := newTestSecretCache(t, "/app/dev/")
+	cached, err := c.load()
+	assert.NoError(t, err)
+	assert.Nil(t, cached)
+
+	env := []string{"API_KEY=key1", "DB_PASS=pass1"}
+	assert.NoError(t, c.save(env, "v1"))
+	cached, err = c.load()
+	assert.NoError(t, err)
+	assert.Equal(t, env, cached.Env)
+	assert.Equal(t, "v1", cached.Version)
+
+	// Only metadata is saved on the cache data, and secret values are not on disk as plaintext
+	data, err := cli.LoadCacheData()
+	assert.NoError(t, err)
+	meta := data.Secrets[filepath.Base(c.filename)]
+	assert.Equal(t, "/app/dev/", meta.Workspace)
+	assert.Equal(t, keySourcePassphrase, meta.KeySource)
+	assert.Equal(t, 2, meta.Count)
+	cacheDir, _ := cli.GetCacheFolder()
+	for _, name := range []string{c.filename, filepath.Join(cacheDir, "cache.json")} {
+		b, err := os.ReadFile(name)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(b), "pass1")
+	}
+	info, err := os.Stat(c.filename)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	t.Setenv(cachePassphraseEnvVar, "wrong")
+	_, err = c.load()
+	assert.ErrorContains(t, err, "failed to decrypt")
+
+	t.Setenv(cachePassphraseEnvVar, "")
+	_, err = c.load()
+	assert.ErrorContains(t, err, cachePassphraseEnvVar)
+
+	// Files are bound to their workspace
+	t.Setenv(cachePassphraseEnvVar, "correct horse")
+	other := newTestSecretCache(t, "/app/prod/")
+	b, err := os.ReadFile(c.filename)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(other.filename, b, 0600))
+	_, err = other.load()
+	assert.ErrorContains(t, err, "failed to decrypt")
+
+	// Expired secrets are stale, but they're not removed
+	assert.False(t, c.stale(cached))
+	c.maxAge = time.Nanosecond
+	cached, err = c.load()
+	assert.NoError(t, err)
+	assert.Equal(t, env, cached.Env)
+	assert.True(t, c.stale(cached))
+	assert.FileExists(t, c.filename)
+	data, err = cli.LoadCacheData()
+	assert.NoError(t, err)
+	assert.Contains(t, data.Secrets, filepath.Base(c.filename))
+}
+
+func TestSecretCacheKeyring(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(cachePassphraseEnvVar, "")
+	keyring.MockInit()
+
+	c := newTestSecretCache(t, "/app/dev/")
+	assert.NoError(t, c.save([]string{"API_KEY=key1"}, "v1"))
+	cached, err := c.load()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"API_KEY=key1"}, cached.Env)
+
+	// A new key could not open the secrets sealed with the previous one
+	assert.NoError(t, keyring.Delete(keyringService, keyringUser))
+	_, err = c.load()
+	assert.Error(t, err)
+	assert.NoError(t, c.save([]string{"API_KEY=key2"}, "v2"))
+	cached, err = c.load()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"API_KEY=key2"}, cached.Env)
+}
@@ -49,7 +49,15 @@ func (p *pluginStoreFromFile) Init(b plugins.CommandProvider) error {
 		logger.Println(cmd.Flags().FlagUsages())
 		return nil
 	})
+	// Keep base command pre run, it loads the request body
+	basePreRunE := cmd.PreRunE
 	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if basePreRunE != nil {
+			if err := basePreRunE(cmd, args); err != nil {
+				return err
+			}
+		}
+
 		content, err := os.ReadFile(args[0])
 		if err != nil {
 			return err
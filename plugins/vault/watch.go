@@ -0,0 +1,298 @@
+package vault
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	sv "github.com/pangeacyber/pangea-go/pangea-sdk/v3/service/vault"
+	"github.com/spf13/cobra"
+)
+
+// Defaults of `vault workspace run --watch` flags
+const (
+	defaultWatchInterval = 30 * time.Second
+	defaultWatchDebounce = 5 * time.Second
+	defaultMaxRestarts   = 10
+)
+
+// Value of `on-change` flag to restart the command when secrets change
+const onChangeRestart = "restart"
+
+// Time a command has to exit after it's asked to, before it's killed
+const restartGracePeriod = 10 * time.Second
+
+// secretSource loads the secrets of `vault workspace run`.
+type secretSource interface {
+	// Load returns the secrets as `NAME=value`, and their version.
+	Load(ctx context.Context) (env []string, version string, err error)
+	// Version returns a value that changes when any of the secrets changes.
+	Version(ctx context.Context) (string, error)
+}
+
+// workspaceSource loads all the secrets on a workspace. Its version is taken from the item versions
+// on the workspace listing, so the secrets are only read when they change.
+type workspaceSource struct {
+	workspace string
+}
+
+func (s *workspaceSource) Load(ctx context.Context) ([]string, string, error) {
+	if s.workspace == "" {
+		return nil, "", errNoWorkspace
+	}
+	logger.Printf("Fetching secrets from: %s\n", s.workspace)
+	client, items, err := s.list(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	env, err := getSecrets(ctx, client, items)
+	if err != nil {
+		return nil, "", err
+	}
+	return env, itemsVersion(items), nil
+}
+
+func (s *workspaceSource) Version(ctx context.Context) (string, error) {
+	_, items, err := s.list(ctx)
+	if err != nil {
+		return "", err
+	}
+	return itemsVersion(items), nil
+}
+
+func (s *workspaceSource) list(ctx context.Context) (sv.Client, []sv.ListItemData, error) {
+	client, err := CreateVaultService()
+	if err != nil {
+		return nil, nil, err
+	}
+	items, err := listWorkspaceSecrets(ctx, client, s.workspace)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list secrets on workspace %s: %w", s.workspace, err)
+	}
+	return client, items, nil
+}
+
+// itemsVersion returns a hash of the names, IDs and versions of the items.
+func itemsVersion(items []sv.ListItemData) string {
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		version := 0
+		if item.CurrentVersion != nil {
+			version = item.CurrentVersion.Version
+		}
+		lines = append(lines, fmt.Sprintf("%s=%s:%d", item.Name, item.ID, version))
+	}
+	return envVersion(lines)
+}
+
+// templateSource loads the variables of an env template. Its version is taken from their values,
+// so references are resolved on every check.
+type templateSource struct {
+	vars map[string]string
+}
+
+func (s *templateSource) Load(ctx context.Context) ([]string, string, error) {
+	env, err := resolveEnvTemplate(s.vars)
+	if err != nil {
+		return nil, "", err
+	}
+	return env, envVersion(env), nil
+}
+
+func (s *templateSource) Version(ctx context.Context) (string, error) {
+	_, version, err := s.Load(ctx)
+	return version, err
+}
+
+// envVersion returns a hash of a list of variables, regardless of their order.
+func envVersion(env []string) string {
+	sorted := append([]string{}, env...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// watchOptions are the settings of `vault workspace run --watch`.
+type watchOptions struct {
+	interval    time.Duration
+	debounce    time.Duration
+	maxRestarts int
+	// Signal sent on changes, or nil to restart the command
+	signal      os.Signal
+	secretsFile string
+}
+
+func getWatchOptions(cmd *cobra.Command) (*watchOptions, error) {
+	opts := &watchOptions{}
+	opts.interval, _ = cmd.Flags().GetDuration("watch-interval")
+	opts.debounce, _ = cmd.Flags().GetDuration("debounce")
+	opts.maxRestarts, _ = cmd.Flags().GetInt("max-restarts")
+	opts.secretsFile, _ = cmd.Flags().GetString("secrets-file")
+	onChange, _ := cmd.Flags().GetString("on-change")
+
+	if opts.interval <= 0 {
+		return nil, errors.New("`watch-interval` should be greater than 0")
+	}
+	if opts.debounce < 0 {
+		return nil, errors.New("`debounce` should not be negative")
+	}
+	if opts.maxRestarts < 0 {
+		return nil, errors.New("`max-restarts` should not be negative")
+	}
+	if onChange != onChangeRestart {
+		sig, err := parseSignal(onChange)
+		if err != nil {
+			return nil, fmt.Errorf("invalid `on-change` value: %w", err)
+		}
+		if opts.secretsFile == "" {
+			return nil, errors.New("`secrets-file` is required to send a signal on changes")
+		}
+		opts.signal = sig
+	}
+	return opts, nil
+}
+
+// watchSubprocess runs a command as execSubprocess does, and restarts it or sends it a signal when the
+// secrets change. Changes are applied once the secrets stay the same for the debounce time, and
+// retried after it if they could not be loaded. After the max restarts the secrets are no longer watched. The CLI exits when the command exits by
+// itself.
+func watchSubprocess(source secretSource, remoteEnv []string, version string, opts *watchOptions, baseCommand string, args []string) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	cmd, group, err := startSubprocess(remoteEnv, baseCommand, args)
+	if err != nil {
+		return err
+	}
+	exited := waitSubprocess(cmd)
+
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+	checks := ticker.C
+
+	debounce := time.NewTimer(opts.debounce)
+	debounce.Stop()
+	var kill <-chan time.Time
+	restarts := 0
+	restarting := false
+	ctx := context.Background()
+
+	for {
+		select {
+		case sig := <-signals:
+			forwardSignal(cmd.Process, sig, group)
+			// The command exits on its own now
+			restarting = false
+
+		case err := <-exited:
+			if !restarting {
+				return subprocessError(err)
+			}
+			restarting = false
+			kill = nil
+			cmd, group, err = startSubprocess(remoteEnv, baseCommand, args)
+			if err != nil {
+				return err
+			}
+			exited = waitSubprocess(cmd)
+
+		case <-checks:
+			current, err := source.Version(ctx)
+			if err != nil {
+				logger.Printf("Failed to check secrets: %v\n", err)
+				continue
+			}
+			if current != version {
+				version = current
+				debounce.Reset(opts.debounce)
+			}
+
+		case <-debounce.C:
+			// Changes are retried until they're applied, as the checks don't see them again
+			env, current, err := source.Load(ctx)
+			if err != nil {
+				logger.Printf("Failed to load secrets, retrying in %v: %v\n", opts.debounce, err)
+				debounce.Reset(opts.debounce)
+				continue
+			}
+			if opts.secretsFile != "" {
+				if err := writeSecretsFile(opts.secretsFile, env); err != nil {
+					logger.Printf("Failed to write secrets file, retrying in %v: %v\n", opts.debounce, err)
+					debounce.Reset(opts.debounce)
+					continue
+				}
+			}
+			version = current
+			remoteEnv = env
+
+			if opts.signal != nil {
+				logger.Printf("Secrets changed, sending %v to %s\n", opts.signal, baseCommand)
+				forwardSignal(cmd.Process, opts.signal, group)
+				continue
+			}
+
+			restarts++
+			logger.Printf("Secrets changed, restarting %s\n", baseCommand)
+			restarting = true
+			stopSubprocess(cmd.Process, group)
+			kill = time.After(restartGracePeriod)
+			if opts.maxRestarts > 0 && restarts >= opts.maxRestarts {
+				logger.Printf("Restarted %s %d times, secrets are no longer watched\n", baseCommand, restarts)
+				ticker.Stop()
+				checks = nil
+			}
+
+		case <-kill:
+			logger.Printf("%s did not exit after %v, killing it\n", baseCommand, restartGracePeriod)
+			killSubprocess(cmd.Process, group)
+			kill = nil
+		}
+	}
+}
+
+// waitSubprocess returns a channel that gets the result of the command when it exits.
+func waitSubprocess(cmd *exec.Cmd) <-chan error {
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	return exited
+}
+
+// Escapes of double quoted values on .env files
+var envValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeSecretsFile writes the secrets to a .env file only readable by the user. It's replaced at once,
+// so readers never get a partial file.
+func writeSecretsFile(name string, env []string) error {
+	var b strings.Builder
+	for _, v := range env {
+		key, value, _ := strings.Cut(v, "=")
+		fmt.Fprintf(&b, "%s=\"%s\"\n", key, envValueEscaper.Replace(value))
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
@@ -0,0 +1,38 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvVersion(t *testing.T) {
+	version := envVersion([]string{"A=1", "B=2"})
+	assert.Equal(t, version, envVersion([]string{"B=2", "A=1"}))
+	assert.NotEqual(t, version, envVersion([]string{"A=1", "B=3"}))
+}
+
+func TestWriteSecretsFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), ".env")
+	env := []string{"PLAIN=value", "QUOTED=say \"hi\"", "MULTILINE=line1\nline2", `PATH=C:\bin`, "EMPTY="}
+	assert.NoError(t, writeSecretsFile(name, env))
+
+	info, err := os.Stat(name)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	f, err := os.Open(name)
+	assert.NoError(t, err)
+	defer f.Close()
+	vars, err := readEnvTemplate(f)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"PLAIN":     "value",
+		"QUOTED":    `say "hi"`,
+		"MULTILINE": "line1\nline2",
+		"PATH":      `C:\bin`,
+		"EMPTY":     "",
+	}, vars)
+}
@@ -0,0 +1,64 @@
+//go:build unix
+
+package vault
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakySource is a secretSource with a rotated secret that fails to load the first times.
+type flakySource struct {
+	mu       sync.Mutex
+	loads    int
+	failures int
+}
+
+func (s *flakySource) Load(ctx context.Context) ([]string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loads++
+	if s.loads <= s.failures {
+		return nil, "", errors.New("service unavailable")
+	}
+	return []string{"SECRET=rotated"}, "2", nil
+}
+
+func (s *flakySource) Version(ctx context.Context) (string, error) {
+	return "2", nil
+}
+
+func TestWatchRetriesFailedLoads(t *testing.T) {
+	source := &flakySource{failures: 2}
+	opts := &watchOptions{
+		interval:    10 * time.Millisecond,
+		debounce:    20 * time.Millisecond,
+		signal:      syscall.SIGHUP,
+		secretsFile: filepath.Join(t.TempDir(), ".env"),
+	}
+
+	// The command exits once it gets the signal sent when the rotation is applied
+	done := make(chan error, 1)
+	go func() {
+		done <- watchSubprocess(source, []string{"SECRET=initial"}, "1", opts, "sh", []string{"-c", "trap 'exit 0' HUP; while :; do sleep 0.05; done"})
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("rotated secrets were not applied")
+	}
+
+	assert.Equal(t, 3, source.loads)
+	b, err := os.ReadFile(opts.secretsFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "SECRET=\"rotated\"\n", string(b))
+}